@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
 	"fmt"
 )
 
@@ -13,6 +14,7 @@ import (
 // CTR: 计算器模式（Counter）
 // CFB: 密码反馈模式（Cipher FeedBack）
 // OFB: 输出反馈模式（Output FeedBack）
+// GCM: 伽罗瓦/计数器模式（Galois/Counter Mode），带认证，可检测密文篡改
 type Mode string
 
 const (
@@ -21,12 +23,30 @@ const (
 	ModeCTR Mode = "CTR"
 	ModeCFB Mode = "CFB"
 	ModeOFB Mode = "OFB"
+	ModeGCM Mode = "GCM"
 )
 
+const (
+	// GCMStandardNonceSize GCM 默认 nonce 长度
+	GCMStandardNonceSize = 12
+	// GCMStandardTagSize GCM 默认认证标签长度
+	GCMStandardTagSize = 16
+	// GCMMinTagSize GCM 允许的最小认证标签长度
+	GCMMinTagSize = 12
+)
+
+// ErrAuthFailed GCM 解密时认证失败, 密文/附加数据被篡改或密钥不匹配
+var ErrAuthFailed = errors.New("aes: message authentication failed")
+
 type Encryptor struct {
 	key  []byte
 	iv   []byte
 	mode Mode
+
+	// 以下仅 GCM 模式使用
+	nonceSize int
+	tagSize   int
+	aad       []byte
 }
 
 func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
@@ -35,6 +55,14 @@ func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("key 长度必须 16/24/32长度: %s", err.Error())
 	}
+	// GCM 为认证加密模式, 无需补码
+	if e.mode == ModeGCM {
+		aead, nonce, err := e.gcm(block)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Seal(nil, nonce, plaintext, e.aad), nil
+	}
 	// 获取秘钥块的长度
 	blockSize := block.BlockSize()
 	// 补码
@@ -74,6 +102,17 @@ func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("key 长度必须 16/24/32长度: %s", err.Error())
 	}
+	if e.mode == ModeGCM {
+		aead, nonce, err := e.gcm(block)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := aead.Open(nil, nonce, ciphertext, e.aad)
+		if err != nil {
+			return nil, ErrAuthFailed
+		}
+		return plaintext, nil
+	}
 	// 获取秘钥块的长度
 	blockSize := block.BlockSize()
 	iv := e.iv[:blockSize]
@@ -121,6 +160,63 @@ func (e *Encryptor) SetIV(iv []byte) error {
 	return nil
 }
 
+// SetNonceSize 设置 GCM 模式的 nonce 长度, nonce 取自 iv 的前 size 字节
+func (e *Encryptor) SetNonceSize(size int) error {
+	if size <= 0 || size > aes.BlockSize {
+		return fmt.Errorf("invalid gcm nonce size:%d, must be in (0, %d]", size, aes.BlockSize)
+	}
+	e.nonceSize = size
+	return nil
+}
+
+// SetTagSize 设置 GCM 模式的认证标签长度
+func (e *Encryptor) SetTagSize(size int) error {
+	if size < GCMMinTagSize || size > GCMStandardTagSize {
+		return fmt.Errorf("invalid gcm tag size:%d, must be in [%d, %d]", size, GCMMinTagSize, GCMStandardTagSize)
+	}
+	e.tagSize = size
+	return nil
+}
+
+// SetAAD 设置 GCM 模式的附加认证数据, 该数据不加密但参与认证, 解密时必须一致
+func (e *Encryptor) SetAAD(aad []byte) {
+	e.aad = aad
+}
+
+// gcm 根据 nonce/tag 长度构造 AEAD, 并返回本次使用的 nonce
+func (e *Encryptor) gcm(block cipher.Block) (cipher.AEAD, []byte, error) {
+	nonceSize, tagSize := e.nonceSize, e.tagSize
+	if nonceSize == 0 {
+		nonceSize = GCMStandardNonceSize
+	}
+	if tagSize == 0 {
+		tagSize = GCMStandardTagSize
+	}
+	if len(e.iv) < nonceSize {
+		return nil, nil, fmt.Errorf("iv length less than gcm nonce size, iv:%d nonce:%d", len(e.iv), nonceSize)
+	}
+
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+	switch {
+	case nonceSize != GCMStandardNonceSize && tagSize != GCMStandardTagSize:
+		// 标准库不支持同时自定义 nonce 与 tag 长度
+		return nil, nil, fmt.Errorf("gcm does not support custom nonce size:%d and tag size:%d at the same time", nonceSize, tagSize)
+	case nonceSize != GCMStandardNonceSize:
+		aead, err = cipher.NewGCMWithNonceSize(block, nonceSize)
+	case tagSize != GCMStandardTagSize:
+		aead, err = cipher.NewGCMWithTagSize(block, tagSize)
+	default:
+		aead, err = cipher.NewGCM(block)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, e.iv[:nonceSize], nil
+}
+
 func NewEncryptor(key []byte, mode Mode) *Encryptor {
 	return &Encryptor{
 		key:  key,
@@ -3,6 +3,7 @@ package aes
 import (
 	"bytes"
 	"crypto/aes"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -194,3 +195,90 @@ func TestEncryptor(t *testing.T) {
 
 	}
 }
+
+func TestEncryptorGCM(t *testing.T) {
+	aad := []byte("additional authenticated data")
+	tests := []struct {
+		name      string
+		key       []byte
+		nonceSize int
+		tagSize   int
+	}{
+		{name: "aes-128-default", key: commonKey128},
+		{name: "aes-256-default", key: commonKey256},
+		{name: "aes-256-nonce-16", key: commonKey256, nonceSize: 16},
+		{name: "aes-192-tag-12", key: commonKey192, tagSize: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEncryptor(tt.key, ModeGCM)
+			if err := e.SetIV(commonIV); err != nil {
+				t.Fatalf("SetIV() failed: %v", err)
+			}
+			if tt.nonceSize != 0 {
+				if err := e.SetNonceSize(tt.nonceSize); err != nil {
+					t.Fatalf("SetNonceSize() failed: %v", err)
+				}
+			}
+			if tt.tagSize != 0 {
+				if err := e.SetTagSize(tt.tagSize); err != nil {
+					t.Fatalf("SetTagSize() failed: %v", err)
+				}
+			}
+			e.SetAAD(aad)
+
+			ciphertext, err := e.Encrypt(commonInput)
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			tagSize := tt.tagSize
+			if tagSize == 0 {
+				tagSize = GCMStandardTagSize
+			}
+			if len(ciphertext) != len(commonInput)+tagSize {
+				t.Errorf("Encrypt() ciphertext length = %d, want %d", len(ciphertext), len(commonInput)+tagSize)
+			}
+			plaintext, err := e.Decrypt(ciphertext)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(plaintext, commonInput) {
+				t.Errorf("Decrypt() plaintext = %v, want %v", plaintext, commonInput)
+			}
+
+			// 篡改密文
+			tampered := append([]byte{}, ciphertext...)
+			tampered[0] ^= 0x1
+			if _, err := e.Decrypt(tampered); !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("Decrypt() tampered ciphertext error = %v, want %v", err, ErrAuthFailed)
+			}
+
+			// 附加数据不一致
+			e.SetAAD([]byte("other data"))
+			if _, err := e.Decrypt(ciphertext); !errors.Is(err, ErrAuthFailed) {
+				t.Errorf("Decrypt() mismatched aad error = %v, want %v", err, ErrAuthFailed)
+			}
+		})
+	}
+}
+
+func TestEncryptorGCMOptions(t *testing.T) {
+	e := NewEncryptor(commonKey256, ModeGCM)
+	if err := e.SetNonceSize(0); err == nil {
+		t.Errorf("SetNonceSize(0) want error")
+	}
+	if err := e.SetNonceSize(aes.BlockSize + 1); err == nil {
+		t.Errorf("SetNonceSize(%d) want error", aes.BlockSize+1)
+	}
+	if err := e.SetTagSize(GCMMinTagSize - 1); err == nil {
+		t.Errorf("SetTagSize(%d) want error", GCMMinTagSize-1)
+	}
+	if err := e.SetIV(commonIV); err != nil {
+		t.Fatalf("SetIV() failed: %v", err)
+	}
+	_ = e.SetNonceSize(16)
+	_ = e.SetTagSize(12)
+	if _, err := e.Encrypt(commonInput); err == nil {
+		t.Errorf("Encrypt() with custom nonce and tag size want error")
+	}
+}
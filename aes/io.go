@@ -0,0 +1,248 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bufSize 流式处理时单次加解密的数据量, 必须是 aes.BlockSize 的整数倍
+const bufSize = 32 * 1024
+
+var (
+	// ErrInvalidPadding 解密后的 PKCS7 补码不合法, 通常是密钥/iv 错误或密文损坏
+	ErrInvalidPadding = errors.New("aes: invalid pkcs7 padding")
+	// ErrStreamUnsupported 该模式不支持流式处理, 目前为 GCM 模式
+	ErrStreamUnsupported = errors.New("aes: mode does not support streaming")
+)
+
+// NewEncryptWriter 返回一个加密 Writer, 写入的明文加密后写入 w.
+// 内存占用与输入大小无关, 可处理任意大小的数据.
+// ECB/CBC 模式仅对最后一个分组补码, 输出与 Encryptor.Encrypt 一次性加密的结果一致;
+// CTR/CFB/OFB 模式不补码, 密文长度与明文相同.
+// 写入完成后必须调用 Close 写出剩余数据, Close 不会关闭 w.
+// 不支持 GCM 模式(返回 ErrStreamUnsupported): 单个 GCM 密文须读完全部数据才能认证, 无法边解密边输出,
+// 需要流式认证加密时使用 NewStreamWriter/NewStreamReader 的分段 GCM 格式.
+func NewEncryptWriter(w io.Writer, e *Encryptor) (io.WriteCloser, error) {
+	bm, s, err := e.newCipher(true)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		return &streamWriter{w: w, s: s, buf: make([]byte, bufSize)}, nil
+	}
+	return &blockWriter{w: w, bm: bm, buf: make([]byte, 0, bufSize)}, nil
+}
+
+// NewDecryptReader 返回一个解密 Reader, 从 r 读取 NewEncryptWriter 生成的密文并解密.
+// ECB/CBC 模式会在读到结尾时校验并去除补码.
+// 与 NewEncryptWriter 相同, GCM 模式返回 ErrStreamUnsupported, 应使用 NewStreamReader.
+func NewDecryptReader(r io.Reader, e *Encryptor) (io.Reader, error) {
+	bm, s, err := e.newCipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		return &cipher.StreamReader{S: s, R: r}, nil
+	}
+	return &blockReader{
+		r:     r,
+		bm:    bm,
+		in:    make([]byte, bufSize),
+		plain: make([]byte, bufSize),
+	}, nil
+}
+
+// newCipher 按模式构造分组模式(ECB/CBC)或流模式(CTR/CFB/OFB)的 cipher, 二者仅返回其一
+func (e *Encryptor) newCipher(encrypt bool) (cipher.BlockMode, cipher.Stream, error) {
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key 长度必须 16/24/32长度: %s", err.Error())
+	}
+	if e.mode == ModeECB {
+		return &ecb{b: block, encrypt: encrypt}, nil, nil
+	}
+	if e.mode == ModeGCM {
+		return nil, nil, fmt.Errorf("%w: %s", ErrStreamUnsupported, e.mode)
+	}
+
+	blockSize := block.BlockSize()
+	if len(e.iv) < blockSize {
+		return nil, nil, fmt.Errorf("iv length less than aes.BlockSize, iv:%d", len(e.iv))
+	}
+	iv := e.iv[:blockSize]
+	switch e.mode {
+	case ModeCBC:
+		if encrypt {
+			return cipher.NewCBCEncrypter(block, iv), nil, nil
+		}
+		return cipher.NewCBCDecrypter(block, iv), nil, nil
+	case ModeCTR:
+		return nil, cipher.NewCTR(block, iv), nil
+	case ModeCFB:
+		if encrypt {
+			return nil, cipher.NewCFBEncrypter(block, iv), nil
+		}
+		return nil, cipher.NewCFBDecrypter(block, iv), nil
+	case ModeOFB:
+		return nil, cipher.NewOFB(block, iv), nil
+	default:
+		return nil, nil, fmt.Errorf("invalid mode: %s", e.mode)
+	}
+}
+
+// ecb 实现 cipher.BlockMode, 标准库未提供 ECB 模式
+type ecb struct {
+	b       cipher.Block
+	encrypt bool
+}
+
+func (x *ecb) BlockSize() int {
+	return x.b.BlockSize()
+}
+
+func (x *ecb) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	for len(src) > 0 {
+		if x.encrypt {
+			x.b.Encrypt(dst[:bs], src[:bs])
+		} else {
+			x.b.Decrypt(dst[:bs], src[:bs])
+		}
+		src, dst = src[bs:], dst[bs:]
+	}
+}
+
+// streamWriter 与 cipher.StreamWriter 不同, 不修改调用方的数据, Close 也不关闭底层 Writer
+type streamWriter struct {
+	w   io.Writer
+	s   cipher.Stream
+	buf []byte
+}
+
+func (sw *streamWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		m := len(p)
+		if m > len(sw.buf) {
+			m = len(sw.buf)
+		}
+		sw.s.XORKeyStream(sw.buf[:m], p[:m])
+		if _, err = sw.w.Write(sw.buf[:m]); err != nil {
+			return
+		}
+		n += m
+		p = p[m:]
+	}
+	return
+}
+
+func (sw *streamWriter) Close() error {
+	return nil
+}
+
+type blockWriter struct {
+	w      io.Writer
+	bm     cipher.BlockMode
+	buf    []byte // 待加密的明文, 写满后整体加密写出
+	closed bool
+}
+
+func (bw *blockWriter) Write(p []byte) (n int, err error) {
+	if bw.closed {
+		return 0, errors.New("aes: write to closed writer")
+	}
+	for len(p) > 0 {
+		m := copy(bw.buf[len(bw.buf):cap(bw.buf)], p)
+		bw.buf = bw.buf[:len(bw.buf)+m]
+		n += m
+		p = p[m:]
+		if len(bw.buf) == cap(bw.buf) {
+			bw.bm.CryptBlocks(bw.buf, bw.buf)
+			if _, err = bw.w.Write(bw.buf); err != nil {
+				return
+			}
+			bw.buf = bw.buf[:0]
+		}
+	}
+	return
+}
+
+// Close 对剩余数据补码并写出最后的分组
+func (bw *blockWriter) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+	bw.buf = PKCS7Padding(bw.buf, bw.bm.BlockSize())
+	bw.bm.CryptBlocks(bw.buf, bw.buf)
+	_, err := bw.w.Write(bw.buf)
+	return err
+}
+
+type blockReader struct {
+	r     io.Reader
+	bm    cipher.BlockMode
+	in    []byte // 已读取未解密的密文
+	n     int    // in 中有效数据长度
+	plain []byte
+	out   []byte // 已解密未返回的明文
+	err   error
+}
+
+func (br *blockReader) Read(p []byte) (int, error) {
+	for len(br.out) == 0 {
+		if br.err != nil {
+			return 0, br.err
+		}
+		br.fill()
+	}
+	n := copy(p, br.out)
+	br.out = br.out[n:]
+	return n, nil
+}
+
+// fill 读取并解密一批数据, 始终保留最后一个分组直到读到结尾, 以便去码
+func (br *blockReader) fill() {
+	bs := br.bm.BlockSize()
+	n, err := io.ReadFull(br.r, br.in[br.n:])
+	br.n += n
+	switch err {
+	case nil:
+		m := br.n - bs
+		br.bm.CryptBlocks(br.plain[:m], br.in[:m])
+		br.out = br.plain[:m]
+		br.n = copy(br.in, br.in[m:br.n])
+	case io.EOF, io.ErrUnexpectedEOF:
+		if br.n == 0 || br.n%bs != 0 {
+			br.err = io.ErrUnexpectedEOF
+			return
+		}
+		br.bm.CryptBlocks(br.plain[:br.n], br.in[:br.n])
+		plaintext, err := unpad(br.plain[:br.n], bs)
+		if err != nil {
+			br.err = err
+			return
+		}
+		br.out = plaintext
+		br.err = io.EOF
+	default:
+		br.err = err
+	}
+}
+
+// unpad 校验并去除 PKCS7 补码
+func unpad(plaintext []byte, blockSize int) ([]byte, error) {
+	length := len(plaintext)
+	padding := int(plaintext[length-1])
+	if padding == 0 || padding > blockSize || padding > length {
+		return nil, ErrInvalidPadding
+	}
+	for _, b := range plaintext[length-padding:] {
+		if int(b) != padding {
+			return nil, ErrInvalidPadding
+		}
+	}
+	return plaintext[:length-padding], nil
+}
@@ -0,0 +1,114 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// writeInPieces 以不规则长度分多次写入, 覆盖分组边界
+func writeInPieces(w io.Writer, data []byte) error {
+	for i, size := 0, 1; len(data) > 0; i, size = i+1, size*3+i {
+		if size > len(data) {
+			size = len(data)
+		}
+		if _, err := w.Write(data[:size]); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+func TestEncryptWriterDecryptReader(t *testing.T) {
+	modes := []Mode{ModeECB, ModeCBC, ModeOFB, ModeCTR, ModeCFB}
+	sizes := []int{0, 1, 15, 16, 17, bufSize - 1, bufSize, bufSize + 1, 3*bufSize + 100}
+
+	for _, mode := range modes {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s-%d", mode, size), func(t *testing.T) {
+				plaintext := make([]byte, size)
+				if _, err := rand.Read(plaintext); err != nil {
+					t.Fatal(err)
+				}
+				e := NewEncryptor(commonKey256, mode)
+				if err := e.SetIV(commonIV); err != nil {
+					t.Fatalf("SetIV() failed: %v", err)
+				}
+
+				var ciphertext bytes.Buffer
+				w, err := NewEncryptWriter(&ciphertext, e)
+				if err != nil {
+					t.Fatalf("NewEncryptWriter() error = %v", err)
+				}
+				if err := writeInPieces(w, plaintext); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+
+				// 分组模式的流式输出应与一次性加密一致
+				if mode == ModeECB || mode == ModeCBC {
+					want, err := e.Encrypt(append([]byte{}, plaintext...))
+					if err != nil {
+						t.Fatalf("Encrypt() error = %v", err)
+					}
+					if !bytes.Equal(ciphertext.Bytes(), want) {
+						t.Errorf("stream ciphertext not equal Encrypt() output")
+					}
+				} else if ciphertext.Len() != size {
+					t.Errorf("ciphertext length = %d, want %d", ciphertext.Len(), size)
+				}
+
+				r, err := NewDecryptReader(iotest.OneByteReader(&ciphertext), e)
+				if err != nil {
+					t.Fatalf("NewDecryptReader() error = %v", err)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("ReadAll() error = %v", err)
+				}
+				if !bytes.Equal(got, plaintext) {
+					t.Errorf("decrypted plaintext not equal, got %d bytes, want %d", len(got), len(plaintext))
+				}
+			})
+		}
+	}
+}
+
+func TestDecryptReaderInvalidCiphertext(t *testing.T) {
+	e := NewEncryptor(commonKey256, ModeCBC)
+	if err := e.SetIV(commonIV); err != nil {
+		t.Fatalf("SetIV() failed: %v", err)
+	}
+	ciphertext, err := e.Encrypt(append([]byte{}, commonInput...))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	r, _ := NewDecryptReader(bytes.NewReader(ciphertext[:len(ciphertext)-1]), e)
+	if _, err := io.ReadAll(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated ciphertext error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	wrong := NewEncryptor(commonKey128, ModeCBC)
+	_ = wrong.SetIV(commonIV)
+	r, _ = NewDecryptReader(bytes.NewReader(ciphertext), wrong)
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrInvalidPadding) {
+		t.Errorf("wrong key error = %v, want %v", err, ErrInvalidPadding)
+	}
+
+	gcm := NewEncryptor(commonKey256, ModeGCM)
+	_ = gcm.SetIV(commonIV)
+	if _, err := NewEncryptWriter(io.Discard, gcm); !errors.Is(err, ErrStreamUnsupported) {
+		t.Errorf("NewEncryptWriter() gcm error = %v, want %v", err, ErrStreamUnsupported)
+	}
+	if _, err := NewDecryptReader(bytes.NewReader(nil), gcm); !errors.Is(err, ErrStreamUnsupported) {
+		t.Errorf("NewDecryptReader() gcm error = %v, want %v", err, ErrStreamUnsupported)
+	}
+}
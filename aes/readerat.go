@@ -0,0 +1,194 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 随机访问解密, 只解密读取范围所涉及的分组/分段.
+// 需要 io.ReadSeeker 时可使用 io.NewSectionReader(ra, 0, ra.Size()).
+
+// CTRReaderAt CTR 模式密文的随机访问解密, 密文须为 NewEncryptWriter 生成的不补码格式
+type CTRReaderAt struct {
+	r     io.ReaderAt
+	block cipher.Block
+	iv    []byte
+	size  int64
+}
+
+// NewCTRReaderAt 返回 CTR 模式的随机访问解密 Reader, size 为密文长度(等于明文长度)
+func NewCTRReaderAt(r io.ReaderAt, size int64, e *Encryptor) (*CTRReaderAt, error) {
+	if e.mode != ModeCTR {
+		return nil, fmt.Errorf("random access is not supported in mode: %s", e.mode)
+	}
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, fmt.Errorf("key 长度必须 16/24/32长度: %s", err.Error())
+	}
+	if len(e.iv) < aes.BlockSize {
+		return nil, fmt.Errorf("iv length less than aes.BlockSize, iv:%d", len(e.iv))
+	}
+	return &CTRReaderAt{r: r, block: block, iv: e.iv[:aes.BlockSize], size: size}, nil
+}
+
+// Size 返回明文长度
+func (c *CTRReaderAt) Size() int64 {
+	return c.size
+}
+
+func (c *CTRReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("aes: negative offset %d", off)
+	}
+	if off >= c.size {
+		return 0, io.EOF
+	}
+	if remain := c.size - off; int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := c.r.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	// 计数器从 off 所在分组开始, 并丢弃分组内 off 之前的密钥流
+	ctr := make([]byte, aes.BlockSize)
+	copy(ctr, c.iv)
+	addCounter(ctr, uint64(off/aes.BlockSize))
+	stream := cipher.NewCTR(c.block, ctr)
+	if skip := int(off % aes.BlockSize); skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	stream.XORKeyStream(p[:n], p[:n])
+
+	if off+int64(n) >= c.size {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// addCounter 将大端计数器 ctr 加上 n, 与 cipher.NewCTR 的计数方式一致
+func addCounter(ctr []byte, n uint64) {
+	for i := len(ctr) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(ctr[i]) + n&0xff
+		ctr[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+}
+
+// StreamReaderAt 分段认证加密格式的随机访问解密, 只解密读取范围所在的段, 每段都会校验认证标签
+type StreamReaderAt struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	nonce  *streamNonce
+	size   int64 // 明文长度
+	chunk  int   // 分段明文长度
+	chunks int64 // 分段数量
+	last   int   // 末段密文长度
+	ad     []byte
+
+	mu    sync.Mutex
+	in    []byte
+	plain []byte
+	index int64 // plain 缓存的段序号, -1 表示无缓存
+}
+
+// NewStreamReaderAt 返回分段认证加密格式的随机访问解密 Reader, size 为 NewStreamWriter 输出的总长度, ad 须与加密时一致
+func NewStreamReaderAt(r io.ReaderAt, size int64, key []byte, ad []byte) (*StreamReaderAt, error) {
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix, chunkSize, err := readStreamHeader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	full := int64(chunkSize + StreamOverhead)
+	payload := size - streamHeaderSize
+	chunks := (payload + full - 1) / full
+	if chunks == 0 {
+		return nil, ErrTruncated
+	}
+	last := payload - (chunks-1)*full
+	if last < StreamOverhead {
+		return nil, ErrTruncated
+	}
+
+	return &StreamReaderAt{
+		r:      r,
+		aead:   aead,
+		nonce:  newStreamNonce(prefix),
+		size:   payload - chunks*StreamOverhead,
+		chunk:  chunkSize,
+		chunks: chunks,
+		last:   int(last),
+		ad:     ad,
+		in:     make([]byte, full),
+		plain:  make([]byte, 0, chunkSize),
+		index:  -1,
+	}, nil
+}
+
+// Size 返回明文长度
+func (s *StreamReaderAt) Size() int64 {
+	return s.size
+}
+
+func (s *StreamReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("aes: negative offset %d", off)
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(p) > 0 && off < s.size {
+		index := off / int64(s.chunk)
+		if err = s.load(index); err != nil {
+			return
+		}
+		m := copy(p, s.plain[off-index*int64(s.chunk):])
+		n += m
+		off += int64(m)
+		p = p[m:]
+	}
+	if len(p) > 0 {
+		err = io.EOF
+	}
+	return
+}
+
+// load 读取并解密第 index 段到 s.plain
+func (s *StreamReaderAt) load(index int64) error {
+	if s.index == index {
+		return nil
+	}
+	s.index = -1
+
+	full := s.chunk + StreamOverhead
+	length, last := full, index == s.chunks-1
+	if last {
+		length = s.last
+	}
+	n, err := s.r.ReadAt(s.in[:length], streamHeaderSize+index*int64(full))
+	if n < length {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	plaintext, err := openChunk(s.aead, s.nonce, s.plain[:0], s.in[:length], s.ad, uint64(index), last, full)
+	if err != nil {
+		return err
+	}
+	s.plain = plaintext
+	s.index = index
+	return nil
+}
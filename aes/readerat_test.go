@@ -0,0 +1,137 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// readRanges 按不同偏移和长度随机读取, 与明文对应部分比较
+func readRanges(t *testing.T, ra io.ReaderAt, plaintext []byte) {
+	t.Helper()
+	size := len(plaintext)
+	ranges := [][2]int{
+		{0, 1}, {0, 16}, {1, 31}, {15, 2}, {size - 1, 1}, {size - 100, 100},
+		{size / 3, size / 3}, {17, size - 17}, {0, size},
+	}
+	for _, rg := range ranges {
+		got := make([]byte, rg[1])
+		n, err := ra.ReadAt(got, int64(rg[0]))
+		if err != nil && !(err == io.EOF && rg[0]+rg[1] == size) {
+			t.Fatalf("ReadAt(%d, %d) error = %v", rg[0], rg[1], err)
+		}
+		if !bytes.Equal(got[:n], plaintext[rg[0]:rg[0]+rg[1]]) {
+			t.Errorf("ReadAt(%d, %d) plaintext not equal", rg[0], rg[1])
+		}
+	}
+
+	// 越过结尾
+	n, err := ra.ReadAt(make([]byte, 10), int64(size-5))
+	if n != 5 || err != io.EOF {
+		t.Errorf("ReadAt() past end = %d, %v, want 5, EOF", n, err)
+	}
+}
+
+func TestCTRReaderAt(t *testing.T) {
+	plaintext := make([]byte, 3*bufSize+123)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	// iv 末尾为 0xff, 覆盖计数器进位
+	iv := bytes.Repeat([]byte{0xff}, 16)
+	e := NewEncryptor(commonKey256, ModeCTR)
+	if err := e.SetIV(iv); err != nil {
+		t.Fatalf("SetIV() failed: %v", err)
+	}
+	var ciphertext bytes.Buffer
+	w, _ := NewEncryptWriter(&ciphertext, e)
+	_, _ = w.Write(plaintext)
+	_ = w.Close()
+
+	ra, err := NewCTRReaderAt(bytes.NewReader(ciphertext.Bytes()), int64(ciphertext.Len()), e)
+	if err != nil {
+		t.Fatalf("NewCTRReaderAt() error = %v", err)
+	}
+	readRanges(t, ra, plaintext)
+
+	if _, err := NewCTRReaderAt(bytes.NewReader(nil), 0, NewEncryptor(commonKey256, ModeCBC)); err == nil {
+		t.Errorf("NewCTRReaderAt() with CBC want error")
+	}
+}
+
+func TestStreamReaderAt(t *testing.T) {
+	const chunkSize = 64
+	for _, size := range []int{chunkSize * 10, chunkSize*10 + 33} {
+		t.Run(fmt.Sprintf("size-%d", size), func(t *testing.T) {
+			plaintext := make([]byte, size)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatal(err)
+			}
+			ciphertext := sealStream(t, plaintext, chunkSize)
+
+			ra, err := NewStreamReaderAt(bytes.NewReader(ciphertext), int64(len(ciphertext)), commonKey256, nil)
+			if err != nil {
+				t.Fatalf("NewStreamReaderAt() error = %v", err)
+			}
+			if ra.Size() != int64(size) {
+				t.Errorf("Size() = %d, want %d", ra.Size(), size)
+			}
+			readRanges(t, ra, plaintext)
+
+			// 通过 io.ReadSeeker 读取
+			rs := io.NewSectionReader(ra, 0, ra.Size())
+			if _, err := rs.Seek(int64(size-70), io.SeekStart); err != nil {
+				t.Fatalf("Seek() error = %v", err)
+			}
+			got, err := io.ReadAll(rs)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, plaintext[size-70:]) {
+				t.Errorf("ReadAll() after Seek plaintext not equal")
+			}
+		})
+	}
+}
+
+func TestStreamReaderAtTampered(t *testing.T) {
+	const chunkSize = 64
+	chunk := chunkSize + StreamOverhead
+	plaintext := make([]byte, chunkSize*4)
+	ciphertext := sealStream(t, plaintext, chunkSize)
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[streamHeaderSize+2*chunk] ^= 0x1
+	ra, err := NewStreamReaderAt(bytes.NewReader(tampered), int64(len(tampered)), commonKey256, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReaderAt() error = %v", err)
+	}
+	// 未被篡改的段可以正常读取
+	if _, err := ra.ReadAt(make([]byte, chunkSize), 0); err != nil {
+		t.Errorf("ReadAt() untouched chunk error = %v", err)
+	}
+	if _, err := ra.ReadAt(make([]byte, 1), 2*chunkSize); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("ReadAt() tampered chunk error = %v, want %v", err, ErrAuthFailed)
+	}
+
+	// 附加数据不一致时任何一段都无法通过认证
+	ra, err = NewStreamReaderAt(bytes.NewReader(ciphertext), int64(len(ciphertext)), commonKey256, []byte("another header"))
+	if err != nil {
+		t.Fatalf("NewStreamReaderAt() error = %v", err)
+	}
+	if _, err := ra.ReadAt(make([]byte, 1), 0); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("ReadAt() wrong ad error = %v, want %v", err, ErrAuthFailed)
+	}
+
+	truncated := ciphertext[:streamHeaderSize+3*chunk]
+	ra, err = NewStreamReaderAt(bytes.NewReader(truncated), int64(len(truncated)), commonKey256, nil)
+	if err != nil {
+		t.Fatalf("NewStreamReaderAt() error = %v", err)
+	}
+	if _, err := ra.ReadAt(make([]byte, 1), 2*chunkSize); !errors.Is(err, ErrTruncated) {
+		t.Errorf("ReadAt() truncated error = %v, want %v", err, ErrTruncated)
+	}
+}
@@ -0,0 +1,277 @@
+package aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 分段认证加密格式(STREAM 构造):
+// 明文按固定长度切分为若干段, 每段使用 AES-GCM 独立加密认证,
+// nonce = 随机前缀(7 字节) || 段序号(4 字节, 大端) || 末段标记(1 字节).
+// 段序号保证各段不能被重排, 末段标记保证截断可被发现.
+// 每段以调用方提供的附加数据 ad 认证(如文件头的 MAC), 使密文与其所属的文件头绑定, ad 不同时解密失败.
+//
+// 格式: 随机前缀(7) | 段长度(4, 大端) | 段密文 ... | 末段密文
+// 除末段外每段密文长度均为 段长度 + StreamOverhead, 末段可以更短(明文为空时仅含认证标签).
+
+const (
+	// DefaultChunkSize 默认分段明文长度
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize 允许的最大分段明文长度
+	MaxChunkSize = 16 * 1024 * 1024
+	// StreamOverhead 每段密文相对明文增加的长度(认证标签)
+	StreamOverhead = GCMStandardTagSize
+
+	streamPrefixSize = 7
+	streamHeaderSize = streamPrefixSize + 4
+	streamMaxChunks  = 1<<32 - 1
+)
+
+var (
+	// ErrTruncated 分段密文被截断, 缺少末段
+	ErrTruncated = errors.New("aes: stream truncated")
+	// ErrInvalidStream 分段格式头部不合法
+	ErrInvalidStream = errors.New("aes: invalid stream header")
+)
+
+// NewStreamWriter 返回分段认证加密的 Writer, 先写出格式头部, 之后写入的明文按 chunkSize 分段加密写入 w, 每段以 ad 作为附加数据.
+// 写入完成后必须调用 Close 写出末段, 否则解密时会被判定为截断. Close 不会关闭 w.
+func NewStreamWriter(w io.Writer, key []byte, chunkSize int, ad []byte) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("invalid chunk size:%d, must be in (0, %d]", chunkSize, MaxChunkSize)
+	}
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(rand.Reader, header[:streamPrefixSize]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(header[streamPrefixSize:], uint32(chunkSize))
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &streamSealer{
+		w:     w,
+		aead:  aead,
+		nonce: newStreamNonce(header[:streamPrefixSize]),
+		buf:   make([]byte, 0, chunkSize+StreamOverhead),
+		size:  chunkSize,
+		ad:    ad,
+	}, nil
+}
+
+// NewStreamReader 返回分段认证解密的 Reader, 从 r 读取 NewStreamWriter 生成的密文, ad 须与加密时一致.
+// 任意一段认证失败立即返回 ErrAuthFailed, 缺少末段返回 ErrTruncated, 此前已返回的明文均已通过认证.
+func NewStreamReader(r io.Reader, key []byte, ad []byte) (io.Reader, error) {
+	aead, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix, chunkSize, err := readStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &streamOpener{
+		r:     r,
+		aead:  aead,
+		nonce: newStreamNonce(prefix),
+		// 多读 1 字节用于判断当前段是否为末段
+		in:    make([]byte, chunkSize+StreamOverhead+1),
+		plain: make([]byte, 0, chunkSize),
+		size:  chunkSize,
+		ad:    ad,
+	}, nil
+}
+
+func newStreamAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("key 长度必须 16/24/32长度: %s", err.Error())
+	}
+	return cipher.NewGCM(block)
+}
+
+func readStreamHeader(r io.Reader) (prefix []byte, chunkSize int, err error) {
+	header := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, ErrInvalidStream
+		}
+		return nil, 0, err
+	}
+	chunkSize = int(binary.BigEndian.Uint32(header[streamPrefixSize:]))
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return nil, 0, fmt.Errorf("%w: chunk size %d", ErrInvalidStream, chunkSize)
+	}
+	return header[:streamPrefixSize], chunkSize, nil
+}
+
+// streamNonce 按段序号与末段标记生成每段的 nonce
+type streamNonce struct {
+	nonce   [GCMStandardNonceSize]byte
+	counter uint64
+}
+
+func newStreamNonce(prefix []byte) *streamNonce {
+	n := &streamNonce{}
+	copy(n.nonce[:], prefix)
+	return n
+}
+
+// next 返回当前段的 nonce, 并将段序号加一
+func (n *streamNonce) next(last bool) ([]byte, error) {
+	nonce, err := n.at(n.counter, last)
+	n.counter++
+	return nonce, err
+}
+
+// at 返回第 index 段的 nonce, 返回值在下次调用前有效
+func (n *streamNonce) at(index uint64, last bool) ([]byte, error) {
+	if index > streamMaxChunks {
+		return nil, errors.New("aes: stream chunk counter overflow")
+	}
+	binary.BigEndian.PutUint32(n.nonce[streamPrefixSize:], uint32(index))
+	n.nonce[GCMStandardNonceSize-1] = 0
+	if last {
+		n.nonce[GCMStandardNonceSize-1] = 1
+	}
+	return n.nonce[:], nil
+}
+
+type streamSealer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	nonce  *streamNonce
+	buf    []byte
+	size   int
+	ad     []byte
+	closed bool
+}
+
+func (s *streamSealer) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, errors.New("aes: write to closed writer")
+	}
+	for len(p) > 0 {
+		// 缓冲区已满且还有后续数据, 说明当前段不是末段
+		if len(s.buf) == s.size {
+			if err = s.seal(false); err != nil {
+				return
+			}
+		}
+		m := copy(s.buf[len(s.buf):s.size], p)
+		s.buf = s.buf[:len(s.buf)+m]
+		n += m
+		p = p[m:]
+	}
+	return
+}
+
+// Close 加密并写出末段
+func (s *streamSealer) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.seal(true)
+}
+
+func (s *streamSealer) seal(last bool) error {
+	nonce, err := s.nonce.next(last)
+	if err != nil {
+		return err
+	}
+	s.buf = s.aead.Seal(s.buf[:0], nonce, s.buf, s.ad)
+	_, err = s.w.Write(s.buf)
+	s.buf = s.buf[:0]
+	return err
+}
+
+type streamOpener struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	nonce *streamNonce
+	in    []byte
+	n     int // in 中已读取的数据长度
+	plain []byte
+	size  int
+	ad    []byte
+	out   []byte
+	err   error
+}
+
+func (s *streamOpener) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.out, s.err = s.next()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// next 读取并解密下一段, 读到末段后返回 io.EOF
+func (s *streamOpener) next() ([]byte, error) {
+	n, err := io.ReadFull(s.r, s.in[s.n:])
+	s.n += n
+	switch err {
+	case nil:
+		// 读满一段且还有后续数据, 当前段不是末段
+		chunk := s.size + StreamOverhead
+		plaintext, err := s.open(s.in[:chunk], false)
+		if err != nil {
+			return nil, err
+		}
+		// 保留多读的 1 字节, 作为下一段的开头
+		s.n = copy(s.in, s.in[chunk:s.n])
+		return plaintext, nil
+	case io.EOF, io.ErrUnexpectedEOF:
+		if s.n < StreamOverhead {
+			return nil, ErrTruncated
+		}
+		plaintext, err := s.open(s.in[:s.n], true)
+		if err != nil {
+			return nil, err
+		}
+		return plaintext, io.EOF
+	default:
+		return nil, err
+	}
+}
+
+func (s *streamOpener) open(chunk []byte, last bool) ([]byte, error) {
+	index := s.nonce.counter
+	s.nonce.counter++
+	return openChunk(s.aead, s.nonce, s.plain[:0], chunk, s.ad, index, last, s.size+StreamOverhead)
+}
+
+// openChunk 以附加数据 ad 解密认证第 index 段, fullSize 为非末段的密文长度
+func openChunk(aead cipher.AEAD, n *streamNonce, dst, chunk, ad []byte, index uint64, last bool, fullSize int) ([]byte, error) {
+	nonce, err := n.at(index, last)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(dst, nonce, chunk, ad)
+	if err == nil {
+		return plaintext, nil
+	}
+	if last && len(chunk) == fullSize {
+		// 末段认证失败时, 若按非末段可以认证通过, 说明文件在段边界处被截断
+		nonce, _ = n.at(index, false)
+		if _, err := aead.Open(dst, nonce, chunk, ad); err == nil {
+			return nil, ErrTruncated
+		}
+	}
+	return nil, ErrAuthFailed
+}
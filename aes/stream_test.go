@@ -0,0 +1,123 @@
+package aes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func sealStream(t *testing.T, plaintext []byte, chunkSize int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewStreamWriter(&buf, commonKey256, chunkSize, nil)
+	if err != nil {
+		t.Fatalf("NewStreamWriter() error = %v", err)
+	}
+	if err := writeInPieces(w, plaintext); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func openStream(ciphertext []byte) ([]byte, error) {
+	r, err := NewStreamReader(iotest.OneByteReader(bytes.NewReader(ciphertext)), commonKey256, nil)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamWriterReader(t *testing.T) {
+	const chunkSize = 64
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize, 10*chunkSize + 7}
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("size-%d", size), func(t *testing.T) {
+			plaintext := make([]byte, size)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatal(err)
+			}
+			ciphertext := sealStream(t, plaintext, chunkSize)
+
+			chunks := (size + chunkSize - 1) / chunkSize
+			if chunks == 0 {
+				chunks = 1
+			}
+			if want := streamHeaderSize + size + chunks*StreamOverhead; len(ciphertext) != want {
+				t.Errorf("ciphertext length = %d, want %d", len(ciphertext), want)
+			}
+
+			got, err := openStream(ciphertext)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("decrypted plaintext not equal, got %d bytes, want %d", len(got), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestStreamReaderTampered(t *testing.T) {
+	const chunkSize = 64
+	plaintext := make([]byte, 4*chunkSize+10)
+	ciphertext := sealStream(t, plaintext, chunkSize)
+	chunk := chunkSize + StreamOverhead
+
+	t.Run("truncated-at-chunk-boundary", func(t *testing.T) {
+		if _, err := openStream(ciphertext[:streamHeaderSize+2*chunk]); !errors.Is(err, ErrTruncated) {
+			t.Errorf("error = %v, want %v", err, ErrTruncated)
+		}
+	})
+	t.Run("truncated-in-chunk", func(t *testing.T) {
+		if _, err := openStream(ciphertext[:len(ciphertext)-1]); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("error = %v, want %v", err, ErrAuthFailed)
+		}
+	})
+	t.Run("reordered", func(t *testing.T) {
+		reordered := append([]byte{}, ciphertext...)
+		first := reordered[streamHeaderSize : streamHeaderSize+chunk]
+		second := reordered[streamHeaderSize+chunk : streamHeaderSize+2*chunk]
+		tmp := append([]byte{}, first...)
+		copy(first, second)
+		copy(second, tmp)
+		if _, err := openStream(reordered); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("error = %v, want %v", err, ErrAuthFailed)
+		}
+	})
+	t.Run("fail-fast", func(t *testing.T) {
+		tampered := append([]byte{}, ciphertext...)
+		tampered[streamHeaderSize+chunk+1] ^= 0x1
+		r, err := NewStreamReader(bytes.NewReader(tampered), commonKey256, nil)
+		if err != nil {
+			t.Fatalf("NewStreamReader() error = %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("error = %v, want %v", err, ErrAuthFailed)
+		}
+		if len(got) != chunkSize {
+			t.Errorf("plaintext before failure = %d bytes, want %d", len(got), chunkSize)
+		}
+	})
+	t.Run("wrong-ad", func(t *testing.T) {
+		r, err := NewStreamReader(bytes.NewReader(ciphertext), commonKey256, []byte("another header"))
+		if err != nil {
+			t.Fatalf("NewStreamReader() error = %v", err)
+		}
+		if _, err := io.ReadAll(r); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("error = %v, want %v", err, ErrAuthFailed)
+		}
+	})
+	t.Run("invalid-header", func(t *testing.T) {
+		if _, err := openStream(ciphertext[:streamHeaderSize-1]); !errors.Is(err, ErrInvalidStream) {
+			t.Errorf("error = %v, want %v", err, ErrInvalidStream)
+		}
+	})
+}
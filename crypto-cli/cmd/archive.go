@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compression 返回 --compress 对应的文件头压缩算法, none 记录为空
+func compression() string {
+	if conf.Compress == utils.CompressNone {
+		return ""
+	}
+	return conf.Compress
+}
+
+// encryptArchive 将目录 dir 以 tar 格式归档, 按 --compress 压缩后加密写入 w, 归档与加密同时进行, 不生成中间文件
+func (o *encOptions) encryptArchive(dir string, w io.Writer) error {
+	hdr := &header.Header{Archive: utils.ArchiveTar, Compression: compression()}
+	v := newVerifier()
+	pr, pw := io.Pipe()
+	go func() {
+		cw, err := utils.NewCompressor(pw, hdr.Compression)
+		if err == nil {
+			var aw io.Writer = cw
+			if v != nil {
+				aw = io.MultiWriter(cw, v.plain)
+			}
+			err = utils.WriteArchive(aw, dir, conf.Include, conf.Exclude)
+			if cerr := cw.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := o.encryptHeader(pr, w, hdr, v)
+	// 加密失败时使归档 goroutine 的写入返回错误并退出
+	pr.CloseWithError(err)
+	return err
+}
+
+// extractArchive 解密 br 中的目录归档并解压到 dir, 返回解压的文件数.
+// 先解压到 dir 同级的临时目录, 解密与校验(自校验哈希/签名)全部成功后再重命名为 dir, 失败时删除临时目录;
+// dir 须不存在或为空目录.
+func (o *decOptions) extractArchive(br *bufio.Reader, ff *fileFormat, dir string) (int, error) {
+	if ff.h == nil || ff.h.Archive == "" {
+		return 0, fmt.Errorf("not an archive, encrypt with --archive")
+	}
+	if ff.h.Archive != utils.ArchiveTar {
+		return 0, fmt.Errorf("unsupported archive:%s", ff.h.Archive)
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return 0, fmt.Errorf("extract dir:%s is not empty", dir)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(filepath.Clean(dir)), "."+filepath.Base(dir)+".tmp-")
+	if err != nil {
+		return 0, err
+	}
+	n, err := o.extractTo(br, ff, tmp)
+	if err == nil {
+		err = os.Chmod(tmp, 0755)
+	}
+	if err == nil {
+		// 空目录可被删除, 非空时 Remove 失败, Rename 随之报错
+		os.Remove(dir)
+		err = os.Rename(tmp, dir)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// extractTo 解密 br 并同时解压到 dir
+func (o *decOptions) extractTo(br *bufio.Reader, ff *fileFormat, dir string) (int, error) {
+	type result struct {
+		n   int
+		err error
+	}
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		// decryptPayload 已按文件头解压, ExtractArchive 会读完 tar 结束标记之后的剩余数据, 使解密完成自校验
+		var res result
+		res.n, res.err = utils.ExtractArchive(pr, dir)
+		pr.CloseWithError(res.err)
+		done <- res
+	}()
+
+	err := o.decryptPayload(br, pw, ff)
+	pw.CloseWithError(err)
+	res := <-done
+	if err != nil {
+		return 0, err
+	}
+	return res.n, res.err
+}
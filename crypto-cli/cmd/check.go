@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-crypto/aes"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"hash"
+	"io"
+	"log"
+	"os"
+)
+
+// verifier --verify 加密后校验输出, 加密时记录明文摘要与文件密钥, 加密完成后重新读取输出的临时文件校验,
+// 校验通过后才替换目标文件(包括被覆盖的原文件)
+type verifier struct {
+	// plain 原始明文(加入元数据块, 压缩之前)的摘要, 指定 --private-key 时与完整解密的结果比对
+	plain hash.Hash
+	// payload 实际加密的数据(元数据块, 压缩之后)的摘要, 与使用文件密钥解密的结果比对
+	payload hash.Hash
+	// key stream 格式的文件密钥或 standard 格式的文件密钥材料
+	key []byte
+}
+
+func newVerifier() *verifier {
+	if !conf.Verify {
+		return nil
+	}
+	return &verifier{plain: sha256.New(), payload: sha256.New()}
+}
+
+// digestWriter 计算解密结果的摘要, 丢弃解密出的元数据
+type digestWriter struct {
+	hash.Hash
+}
+
+func (digestWriter) setMetadata(*utils.Metadata) {}
+
+// check 重新读取 w 中已写入的加密结果, 校验文件头与 hdr 一致, 再校验完整性:
+// 指定 --private-key 时使用私钥完整解密(含解压, 签名验证)并比对明文摘要;
+// 否则 stream 格式使用文件密钥解密, 校验每个分段的认证标签并比对加密数据的摘要, standard 格式校验末尾的自校验哈希.
+func (v *verifier) check(w io.Writer, hdr *header.Header, dec *decOptions) error {
+	out, ok := w.(*output)
+	if !ok || out.tmp == "" {
+		return errors.New("--verify requires a file output")
+	}
+	fr, err := os.Open(out.tmp)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	// 不经过缓冲读取文件头, 之后 fr 位于加密数据开头
+	h, err := header.Read(fr)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	want, _ := json.Marshal(hdr)
+	got, _ := json.Marshal(h)
+	if !bytes.Equal(got, want) {
+		return errors.New("verify: header mismatch")
+	}
+	if err := h.Verify(v.key); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	var level string
+	switch {
+	case dec != nil:
+		if _, err := fr.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		plain := digestWriter{sha256.New()}
+		if err := dec.decrypt(fr, plain); err != nil {
+			return fmt.Errorf("verify: decrypt with private key: %w", err)
+		}
+		if !bytes.Equal(plain.Sum(nil), v.plain.Sum(nil)) {
+			return errors.New("verify: plaintext mismatch")
+		}
+		level = "decrypted with private key, plaintext matched"
+	case h.Format == header.FormatStream:
+		sr, err := aes.NewStreamReader(fr, v.key, h.MAC())
+		if err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		payload := sha256.New()
+		if _, err := io.Copy(payload, sr); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		if !bytes.Equal(payload.Sum(nil), v.payload.Sum(nil)) {
+			return errors.New("verify: payload mismatch")
+		}
+		level = "authentication tags matched"
+	default:
+		sum, err := utils.NewHash(h.Hash)
+		if err != nil {
+			return err
+		}
+		if err := utils.CheckSum(fr, sum); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		level = h.Hash + " checksum matched"
+	}
+	log.Printf("[INFO] verified %s, %s", out.target, level)
+	return nil
+}
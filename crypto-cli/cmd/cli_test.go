@@ -0,0 +1,539 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cliEnv 为 1 时测试进程作为 crypto-cli 运行, 命令行参数即 crypto-cli 的参数.
+// 命令失败时以 log.Fatalf 退出, 需要在子进程中执行才能检查退出码.
+const cliEnv = "GO_CRYPTO_TEST_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(cliEnv) == "1" {
+		rootCmd.SetArgs(os.Args[1:])
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCLI 在子进程中执行 crypto-cli, 返回退出码与标准输出, 标准错误的内容
+func runCLI(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	return runCLIIn(t, "", args...)
+}
+
+// runCLIIn 同 runCLI, 以 dir 为工作目录执行 crypto-cli
+func runCLIIn(t *testing.T, dir string, args ...string) (int, string) {
+	t.Helper()
+	cmd := cliCommand(t, args...)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	return exitCode(t, cmd), output.String()
+}
+
+// cliCommand 返回执行 crypto-cli 的子进程, 忽略用户的配置文件, 调用方可再设置输入输出与环境变量
+func cliCommand(t *testing.T, args ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), cliEnv+"=1", "XDG_CONFIG_HOME="+t.TempDir())
+	return cmd
+}
+
+// exitCode 执行 cmd 并返回退出码
+func exitCode(t *testing.T, cmd *exec.Cmd) int {
+	t.Helper()
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("run crypto-cli error = %v", err)
+	}
+	return 0
+}
+
+// testFiles 在临时目录中生成 x25519 密钥对与明文文件, 返回目录
+func testFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, _, err := utils.GenKeyFiles(&utils.KeyOptions{Type: utils.KeyTypeX25519, Dir: dir}); err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	plain := bytes.Repeat([]byte("crypto-cli test data\n"), 10000)
+	if err := os.WriteFile(filepath.Join(dir, "plain"), plain, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// tamperHeader 替换加密文件头内容中的 old 为 new 并修正头部长度, 模拟篡改文件头
+func tamperHeader(t *testing.T, path, old, new string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := len(header.Magic) + 4
+	n := int(binary.BigEndian.Uint32(b[len(header.Magic):start]))
+	body := string(b[start : start+n])
+	if !strings.Contains(body, old) {
+		t.Fatalf("header %s does not contain %s", body, old)
+	}
+	body = strings.Replace(body, old, new, 1)
+	out := append([]byte{}, b[:start]...)
+	binary.BigEndian.PutUint32(out[len(header.Magic):], uint32(len(body)))
+	out = append(out, body...)
+	if err := os.WriteFile(path, append(out, b[start+n:]...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecryptTamperedCompression(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	enc := filepath.Join(dir, "plain.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "--compress", "gzip", "-f", filepath.Join(dir, "plain"), "-o", enc); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	orig, err := os.ReadFile(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{name: "none", old: "", new: ""},
+		{name: "strip", old: `,"compression":"gzip"`, new: ""},
+		{name: "change", old: `"compression":"gzip"`, new: `"compression":"zstd"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(enc, orig, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.old != "" {
+				tamperHeader(t, enc, tt.old, tt.new)
+			}
+			out := filepath.Join(t.TempDir(), "plain.out")
+			code, output := runCLI(t, "decrypt", "--private-key", priKey, "-f", enc, "-o", out)
+			if tt.old == "" {
+				if code != 0 {
+					t.Fatalf("decrypt exit code = %d, output:\n%s", code, output)
+				}
+				got, _ := os.ReadFile(out)
+				want, _ := os.ReadFile(filepath.Join(dir, "plain"))
+				if !bytes.Equal(got, want) {
+					t.Errorf("decrypt plaintext mismatch")
+				}
+				return
+			}
+			if code != 1 || !strings.Contains(output, header.ErrBadMAC.Error()) {
+				t.Errorf("decrypt exit code = %d, want 1 with %q, output:\n%s", code, header.ErrBadMAC, output)
+			}
+			if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("decrypt of tampered file left output, stat error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPreserveMetadataHeader(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	enc := filepath.Join(dir, "plain.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "--preserve", "-f", filepath.Join(dir, "plain"), "-o", enc); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	info, err := inspectFile(enc)
+	if err != nil {
+		t.Fatalf("inspectFile() error = %v", err)
+	}
+	if !info.Metadata {
+		t.Errorf("inspectFile() Metadata = false, want true")
+	}
+	var buf bytes.Buffer
+	info.print(&buf)
+	if !strings.Contains(buf.String(), "元数据: 已保存") {
+		t.Errorf("print() missing metadata line:\n%s", buf.String())
+	}
+
+	// 去掉元数据标记后元数据块会被当作明文输出, 文件头 MAC 必须拒绝
+	tamperHeader(t, enc, `,"metadata":true`, "")
+	out := filepath.Join(t.TempDir(), "plain.out")
+	if code, output := runCLI(t, "decrypt", "--private-key", priKey, "-f", enc, "-o", out); code != 1 || !strings.Contains(output, header.ErrBadMAC.Error()) {
+		t.Errorf("decrypt exit code = %d, want 1 with %q, output:\n%s", code, header.ErrBadMAC, output)
+	}
+}
+
+func TestDecryptSignerStatus(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	for _, name := range []string{"alice", "mallory"} {
+		if _, _, err := utils.GenKeyFiles(&utils.KeyOptions{Type: utils.KeyTypeEd25519, Dir: dir, Name: name}); err != nil {
+			t.Fatalf("GenKeyFiles() error = %v", err)
+		}
+	}
+	aliceKey, alicePub := utils.KeyPaths(dir, "alice")
+	_, malloryPub := utils.KeyPaths(dir, "mallory")
+	signed := filepath.Join(dir, "signed.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "--sign-key", aliceKey, "-f", filepath.Join(dir, "plain"), "-o", signed); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	unsigned := filepath.Join(dir, "unsigned.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "-f", filepath.Join(dir, "plain"), "-o", unsigned); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		signer string
+		code   int
+		want   string
+	}{
+		{name: "unsigned", file: unsigned, want: "file is not signed"},
+		{name: "unsigned-signer", file: unsigned, signer: alicePub, code: 1, want: utils.ErrNoSignature.Error()},
+		{name: "embedded-key", file: signed, want: "signer is NOT authenticated"},
+		{name: "signer", file: signed, signer: alicePub, want: "signed by --signer"},
+		{name: "wrong-signer", file: signed, signer: malloryPub, code: 1, want: utils.ErrBadSignature.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"decrypt", "--private-key", priKey, "-f", tt.file, "-o", filepath.Join(t.TempDir(), "plain.out")}
+			if tt.signer != "" {
+				args = append(args, "--signer", tt.signer)
+			}
+			code, output := runCLI(t, args...)
+			if code != tt.code || !strings.Contains(output, tt.want) {
+				t.Errorf("decrypt exit code = %d, want %d with %q, output:\n%s", code, tt.code, tt.want, output)
+			}
+		})
+	}
+}
+
+func TestVerifyCorruptedHeader(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	tests := []struct {
+		name   string
+		format string
+		key    string
+	}{
+		{name: "stream", format: formatStream, key: pubKey},
+		{name: "standard", format: formatStandard, key: filepath.Join("..", "public.key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := filepath.Join(t.TempDir(), "plain.enc")
+			if code, output := runCLI(t, "encrypt", "--public-key", tt.key, "--format", tt.format, "-f", filepath.Join(dir, "plain"), "-o", enc); code != 0 {
+				t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+			}
+			key := priKey
+			if tt.format == formatStandard {
+				key = filepath.Join("..", "private.key")
+			}
+			if code, output := runCLI(t, "verify", "--private-key", key, enc); code != 0 {
+				t.Fatalf("verify exit code = %d, output:\n%s", code, output)
+			}
+
+			// 修改文件头中的一个字节, 文件头 MAC 校验失败
+			b, err := os.ReadFile(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := int(binary.BigEndian.Uint32(b[len(header.Magic):]))
+			i := bytes.Index(b[:len(header.Magic)+4+n], []byte(`"cipher":"`)) + len(`"cipher":"`)
+			b[i] ^= 0x20
+			if err := os.WriteFile(enc, b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if code, output := runCLI(t, "verify", "--private-key", key, enc); code != 1 || !strings.Contains(output, header.ErrBadMAC.Error()) {
+				t.Errorf("verify exit code = %d, want 1 with %q, output:\n%s", code, header.ErrBadMAC, output)
+			}
+		})
+	}
+}
+
+func TestShredImpliesVerify(t *testing.T) {
+	dir := testFiles(t)
+	_, pubKey := utils.KeyPaths(dir, "")
+	plain, err := os.ReadFile(filepath.Join(dir, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, name := range []string{"a", "b"} {
+		f := filepath.Join(dir, name)
+		if err := os.WriteFile(f, plain, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	out := filepath.Join(dir, "out")
+	args := append([]string{"encrypt", "--public-key", pubKey, "--shred", "-o", out}, files...)
+	code, output := runCLI(t, args...)
+	if code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	// 批量加密时每个文件都在校验通过后才粉碎
+	for _, f := range files {
+		if !strings.Contains(output, "verified "+filepath.Join(out, filepath.Base(f))) {
+			t.Errorf("%s is shredded without verify, output:\n%s", f, output)
+		}
+		if _, err := os.Stat(f); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s is not shredded, stat error = %v", f, err)
+		}
+	}
+}
+
+func TestKeygenRSABits(t *testing.T) {
+	tests := []struct {
+		bits string
+		code int
+	}{
+		{bits: "1024", code: 1},
+		{bits: "2048", code: 1},
+		{bits: "3072", code: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bits, func(t *testing.T) {
+			dir := t.TempDir()
+			code, output := runCLI(t, "keygen", "--bits", tt.bits, "--out-dir", dir)
+			if code != tt.code {
+				t.Errorf("keygen --bits %s exit code = %d, want %d, output:\n%s", tt.bits, code, tt.code, output)
+			}
+			_, pubKey := utils.KeyPaths(dir, "")
+			if _, err := os.Stat(pubKey); (err == nil) != (tt.code == 0) {
+				t.Errorf("keygen --bits %s public key stat error = %v", tt.bits, err)
+			}
+		})
+	}
+}
+
+func TestEncryptGenerateKeyBits(t *testing.T) {
+	dir := testFiles(t)
+	work := t.TempDir()
+	if code, output := runCLIIn(t, work, "encrypt", "-g", "-f", filepath.Join(dir, "plain"), "-o", filepath.Join(work, "plain.enc")); code != 0 {
+		t.Fatalf("encrypt -g exit code = %d, output:\n%s", code, output)
+	}
+	_, pubPath := utils.KeyPaths(work, "")
+	data, err := os.ReadFile(pubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("invalid public key pem")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePKIXPublicKey() error = %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("encrypt -g key type = %T, want *rsa.PublicKey", pub)
+	}
+	if bits := rsaPub.N.BitLen(); bits < utils.DefaultRsaKeyBits {
+		t.Errorf("encrypt -g rsa key bits = %d, want >= %d", bits, utils.DefaultRsaKeyBits)
+	}
+}
+
+// dirNames 返回目录下的文件名
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestFailedInPlaceKeepsOriginal(t *testing.T) {
+	keys := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(keys, "")
+	if _, _, err := utils.GenKeyFiles(&utils.KeyOptions{Type: utils.KeyTypeX25519, Dir: keys, Name: "other"}); err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	otherKey, _ := utils.KeyPaths(keys, "other")
+	plain, err := os.ReadFile(filepath.Join(keys, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := filepath.Join(t.TempDir(), "plain.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "-f", filepath.Join(keys, "plain"), "-o", enc); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	ciphertext, err := os.ReadFile(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		args []string
+	}{
+		{name: "decrypt-wrong-key", data: ciphertext, args: []string{"decrypt", "--private-key", otherKey}},
+		{name: "decrypt-truncated", data: ciphertext[:len(ciphertext)-100], args: []string{"decrypt", "--private-key", priKey}},
+		{name: "encrypt-verify-wrong-key", data: plain, args: []string{"encrypt", "--public-key", pubKey, "--verify", "--private-key", otherKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f := filepath.Join(dir, "file")
+			if err := os.WriteFile(f, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			// 不指定 -o 时覆盖原文件
+			code, output := runCLI(t, append(tt.args, "-f", f)...)
+			if code != 1 {
+				t.Fatalf("exit code = %d, want 1, output:\n%s", code, output)
+			}
+			got, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("original file is modified")
+			}
+			if names := dirNames(t, dir); len(names) != 1 || names[0] != "file" {
+				t.Errorf("temporary files left: %v", names)
+			}
+		})
+	}
+}
+
+func TestStdioPipe(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	plain, err := os.ReadFile(filepath.Join(dir, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []string{"none", "zstd"} {
+		t.Run(compress, func(t *testing.T) {
+			var ciphertext, logs bytes.Buffer
+			enc := cliCommand(t, "encrypt", "--public-key", pubKey, "--compress", compress, "-f", "-", "-o", "-")
+			enc.Stdin = bytes.NewReader(plain)
+			enc.Stdout = &ciphertext
+			enc.Stderr = &logs
+			if code := exitCode(t, enc); code != 0 {
+				t.Fatalf("encrypt exit code = %d, stderr:\n%s", code, logs.String())
+			}
+			// 标准输出只有加密数据: 以文件头开始, 不含日志
+			if !bytes.HasPrefix(ciphertext.Bytes(), []byte(header.Magic)) || bytes.Contains(ciphertext.Bytes(), []byte("[INFO]")) {
+				t.Errorf("encrypt stdout is not pure ciphertext")
+			}
+			if !strings.Contains(logs.String(), "[INFO]") {
+				t.Errorf("encrypt logs are not written to stderr")
+			}
+
+			var decrypted bytes.Buffer
+			logs.Reset()
+			dec := cliCommand(t, "decrypt", "--private-key", priKey, "-f", "-", "-o", "-")
+			dec.Stdin = bytes.NewReader(ciphertext.Bytes())
+			dec.Stdout = &decrypted
+			dec.Stderr = &logs
+			if code := exitCode(t, dec); code != 0 {
+				t.Fatalf("decrypt exit code = %d, stderr:\n%s", code, logs.String())
+			}
+			if !bytes.Equal(decrypted.Bytes(), plain) {
+				t.Errorf("decrypt stdout does not match the plaintext, stderr:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	const config = `security: aes-256-ctr
+jobs: 2
+profiles:
+  backups:
+    security: aes-256-gcm
+`
+	// 无文件头的文件视为旧版本文件, inspect 无需密钥
+	legacy := filepath.Join(t.TempDir(), "legacy.enc")
+	if err := os.WriteFile(legacy, []byte("legacy data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// xdg 为 true 时配置文件写到 $XDG_CONFIG_HOME/crypto-cli/config.yaml, 否则通过 --config 指定
+		xdg  bool
+		file string
+		env  []string
+		args []string
+		code int
+		want []string
+	}{
+		{name: "default", want: []string{"Security:aes-256-cbc", "Jobs:1"}},
+		{name: "xdg", xdg: true, file: config, want: []string{"Security:aes-256-ctr", "Jobs:2"}},
+		{name: "config-flag", file: config, want: []string{"Security:aes-256-ctr", "Jobs:2"}},
+		{name: "env-over-file", file: config, env: []string{"CRYPTO_CLI_SECURITY=aes-256-ofb"}, want: []string{"Security:aes-256-ofb", "Jobs:2"}},
+		{name: "profile-over-file", file: config, args: []string{"--profile", "backups"}, want: []string{"Security:aes-256-gcm", "Jobs:2"}},
+		{name: "profile-env", xdg: true, file: config, env: []string{"CRYPTO_CLI_PROFILE=backups"}, want: []string{"Security:aes-256-gcm"}},
+		{name: "env-over-profile", file: config, env: []string{"CRYPTO_CLI_SECURITY=aes-256-ofb"}, args: []string{"--profile", "backups"}, want: []string{"Security:aes-256-ofb"}},
+		{name: "flag-over-env", file: config, env: []string{"CRYPTO_CLI_SECURITY=aes-256-ofb", "CRYPTO_CLI_JOBS=3"}, args: []string{"--profile", "backups", "--security", "aes-256-cfb"}, want: []string{"Security:aes-256-cfb", "Jobs:3"}},
+		{name: "missing-profile", file: config, args: []string{"--profile", "nope"}, code: 1, want: []string{"profile:nope not found"}},
+		{name: "missing-config", args: []string{"--config", filepath.Join(t.TempDir(), "none.yaml")}, code: 1, want: []string{"read config file"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xdg := t.TempDir()
+			args := []string{"inspect", "-f", legacy}
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if tt.xdg {
+					path = filepath.Join(xdg, "crypto-cli", "config.yaml")
+				} else {
+					args = append(args, "--config", path)
+				}
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := cliCommand(t, append(args, tt.args...)...)
+			// 后出现的环境变量优先
+			cmd.Env = append(cmd.Env, "XDG_CONFIG_HOME="+xdg, "CRYPTO_CLI_PROFILE=")
+			cmd.Env = append(cmd.Env, tt.env...)
+			var output bytes.Buffer
+			cmd.Stdout = &output
+			cmd.Stderr = &output
+			if code := exitCode(t, cmd); code != tt.code {
+				t.Fatalf("exit code = %d, want %d, output:\n%s", code, tt.code, output.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, output.String())
+				}
+			}
+		})
+	}
+}
@@ -5,19 +5,18 @@ package cmd
 
 import (
 	"bufio"
-	"bytes"
-	"crypto/aes"
-	"crypto/md5"
+	"errors"
 	"fmt"
 	"github.com/jan-bar/EncryptionFile"
+	"go-crypto/crypto-cli/header"
 	"go-crypto/crypto-cli/utils"
 	"hash"
 	"io"
 	"log"
 	"os"
-	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // decryptCmd represents the decrypt command
@@ -27,8 +26,21 @@ var decryptCmd = &cobra.Command{
 	Long: `使用私钥解密文件. 
 示例:
 
-crypto-cli decrypt --private-key private.key -f your-src.file -o unciphered.file`,
-	//PreRun: initDecryptor,
+crypto-cli decrypt --private-key private.key -f your-src.file -o unciphered.file
+crypto-cli decrypt --private-key private.key --format stream -f your-src.file -o unciphered.file
+crypto-cli decrypt --private-key x25519.private.key -f your-src.file -o unciphered.file
+crypto-cli decrypt --private-key private.key --signer signing.public.key -f your-src.file -o unciphered.file   要求文件由指定签名者签名
+crypto-cli decrypt --private-key private.key --format stream --range 1024:4096 -f your-src.file -o part.file
+crypto-cli decrypt --passphrase -f your-src.file -o unciphered.file
+crypto-cli decrypt --private-key private.key -r -f logs.enc -o logs
+crypto-cli decrypt --private-key private.key -j 0 *.enc   使用全部 CPU 并发解密多个文件, 覆盖原文件
+crypto-cli decrypt --private-key private.key --preserve -f app.conf.enc -o /etc/app/   恢复原始文件名, 权限, 时间与扩展属性
+crypto-cli decrypt --private-key private.key -f backup.enc --extract data   解密并解压目录归档
+aws s3 cp s3://bucket/db.enc - | crypto-cli decrypt --private-key private.key -f - -o - | psql db
+CRYPTO_CLI_KEY_PASSPHRASE=xxx crypto-cli decrypt --private-key protected.key -f your-src.file -o unciphered.file`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		Validate(args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("[INFO] decrypt called")
 		DecData(cmd, args)
@@ -48,99 +60,361 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// decryptCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	decryptCmd.Flags().String("extract", "", `解密 encrypt --archive 生成的文件并解压到指定目录, 目录须不存在或为空
+拒绝绝对路径与包含 .. 的路径, 只解压普通文件与目录, 解密校验全部成功后才生成目录`)
+
+	viper.BindPFlags(decryptCmd.Flags())
 }
 
-func decrypt(cmd *cobra.Command, args []string) {
-	in, err := os.Open(conf.File)
+func DecData(cmd *cobra.Command, args []string) {
+	// 只解密部分数据时不能覆盖原文件, 且需要随机访问输入文件
+	if conf.Range != "" && conf.Out == "" {
+		log.Fatalf("[FATA] --range must be used with --out")
+	}
+	if conf.Range != "" && conf.File == stdio {
+		log.Fatalf("[FATA] --range does not support stdin")
+	}
+
+	opts := newDecOptions(cmd)
+	if len(inputs) > 0 {
+		if failed := runBatch(".dec", opts.decrypt); failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	cleanupOnInterrupt()
+	in, err := openInput(conf.File)
 	if err != nil {
 		log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
 	}
-	defer in.Close()
+	br := bufio.NewReader(in)
+
+	// 根据文件头识别格式与加密算法, 无需指定 --format 与 --security
+	ff, err := opts.parse(br)
+	if err != nil {
+		log.Fatalf("[FATA] file:%s %s", conf.File, err)
+	}
 
-	out, err := os.OpenFile("plaintext.txt", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
-	defer out.Close()
+	if conf.Extract != "" {
+		n, err := opts.extractArchive(br, ff, conf.Extract)
+		if err != nil {
+			log.Fatalf("[FATA] extract file:%s to dir:%s err:%s", conf.File, conf.Extract, err)
+		}
+		log.Printf("[INFO] extracted %d files to %s", n, conf.Extract)
+		return
+	}
 
-	num := 0
-	//逐行读取密文，进行解密，写入文件
-	br := bufio.NewReader(in)
-	for {
-		line, err := br.ReadBytes('\n')
-		if err == io.EOF {
-			break
+	if conf.Range != "" {
+		start, end, err := utils.ParseRange(conf.Range)
+		if err != nil {
+			log.Fatalf("[FATA] %s", err)
 		}
+		// 复用已解出文件密钥并校验过的文件头, 不再重复口令派生/私钥运算
+		fr := in.(*os.File)
+		pos, err := fr.Seek(0, io.SeekCurrent)
 		if err != nil {
-			log.Printf("[ERROR] read file err:%s", err)
-			continue
+			log.Fatalf("[FATA] file:%s %s", conf.File, err)
 		}
-		// skip iv
-		if num == 0 {
-			num++
-			continue
+		err = writeOutput(".dec", func(w io.Writer) error {
+			defer in.Close()
+			return decRangeFile(fr, pos-int64(br.Buffered()), w, ff, opts, start, end)
+		})
+		if err != nil {
+			log.Fatalf("[FATA] Could not decrypt range:%s of file:%s, err:%v", conf.Range, conf.File, err)
+		}
+		return
+	}
+
+	// 签名验证失败等任何错误都不会输出解密结果, 也不会修改原文件
+	err = writeOutput(".dec", func(w io.Writer) error {
+		// 覆盖原文件前关闭输入
+		defer in.Close()
+		return opts.decryptPayload(br, w, ff)
+	})
+	if err != nil {
+		log.Fatalf("[FATA] Could not decrypt file:%s, err:%v", conf.File, err)
+	}
+}
+
+// decOptions 解密参数, 由命令行参数一次性解析, 所有文件共用
+type decOptions struct {
+	priKey []byte
+	id     utils.Identity
+	// signerKey 不为空时要求文件由其对应的私钥签名
+	signerKey []byte
+	// security 命令行是否显式指定了 --security
+	security bool
+	// quiet 为 true 时不打印签名状态, 用于 verify, 签名状态在每个文件的结果中给出
+	quiet bool
+}
+
+// newDecOptions 读取口令/私钥/签名者公钥, 参数错误时直接退出
+func newDecOptions(cmd *cobra.Command) *decOptions {
+	opts := &decOptions{security: cmd.Flags().Changed("security")}
+	if conf.Passphrase {
+		pass, err := utils.ReadPassphrase(conf.PassphraseFile, utils.PassphraseEnv, "请输入口令", false)
+		if err != nil {
+			log.Fatalf("[FATA] read passphrase error:%s", err)
 		}
-		log.Printf("[INFO] buf:%#v", line)
-		plaintext, err := encryptor.Decrypt(bytes.TrimSuffix(line, []byte("\n")))
+		opts.id = &utils.PassphraseIdentity{Passphrase: pass}
+	} else {
+		// read from file
+		var err error
+		opts.priKey, err = utils.ReadPrivateKey(conf.PrivateKey, conf.KeyPassphraseFile)
 		if err != nil {
-			log.Fatalf("[FATA] decrypt error: %v", err)
+			log.Fatalf("[FATA] Could not read private key file:%s", err)
 		}
-		log.Printf("[INFO] plaintext:%#v", plaintext)
-		if _, err := out.Write(plaintext); err != nil {
-			log.Fatalf("[FATA] write plaintext into cipher file error: %v", err)
+		if opts.id, err = utils.NewIdentity(opts.priKey); err != nil {
+			log.Fatalf("[FATA] invalid private key:%s", err)
 		}
-		num++
 	}
+	if conf.Signer != "" {
+		var err error
+		if opts.signerKey, err = os.ReadFile(conf.Signer); err != nil {
+			log.Fatalf("[FATA] Could not read signer public key file:%s", err)
+		}
+	}
+	return opts
+}
+
+// fileFormat 由文件头确定的加密文件格式
+type fileFormat struct {
+	// h 文件头, 旧版本生成的无文件头文件为 nil
+	h        *header.Header
+	format   string
+	security string
+	// key stream 格式的文件密钥, 由 parse 从文件头解出
+	key []byte
+	// keyData standard 格式(版本 7 起) RSA 解出的 密钥材料 + 0 + iv, 由 parse 解出
+	keyData []byte
 }
 
-func initDecryptor(cmd *cobra.Command, args []string) {
-	encryptor = utils.InitAesEncryptor(&conf)
+// decrypt 根据文件头识别格式与加密算法, 解密 r 写入 w.
+// 签名验证失败时返回 utils.ErrBadSignature 或 utils.ErrNoSignature, 此时调用方须丢弃输出.
+func (o *decOptions) decrypt(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	ff, err := o.parse(br)
+	if err != nil {
+		return err
+	}
+	return o.decryptPayload(br, w, ff)
+}
 
-	// The IV needs to be unique, but not secure. Therefore it's common to
-	// include it at the beginning of the ciphertext.
-	file, err := os.Open(conf.File)
-	defer file.Close()
+// parse 从 br 读取文件头, 解出文件密钥并校验文件头 MAC, 确定格式与加密算法; 无文件头时使用命令行参数.
+// 命令行显式指定的 --security 与文件头不一致时返回错误, 避免以错误的算法解密.
+func (o *decOptions) parse(br *bufio.Reader) (*fileFormat, error) {
+	h, err := peekHeader(br)
 	if err != nil {
+		return nil, fmt.Errorf("read header err:%w", err)
+	}
+	ff := &fileFormat{h: h, format: conf.Format, security: conf.Security}
+	if h == nil {
+		return ff, nil
 	}
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(file, iv); err != nil {
-		log.Fatalf("[FATA] init encrypt error: %v", err)
+	// MAC 校验通过之前只使用 Format 与 Recipients 解出文件密钥
+	if err := o.authenticate(br, ff); err != nil {
+		return nil, err
 	}
-	encryptor.SetIV(iv)
-	fmt.Println("Decrypt iv: ", iv)
+	switch h.Format {
+	case header.FormatStream:
+		// 版本 1-3 未记录加密算法, 固定为 streamCipher
+		if h.Cipher != "" && h.Cipher != streamCipher {
+			return nil, fmt.Errorf("unsupported stream cipher:%s", h.Cipher)
+		}
+	case header.FormatStandard:
+		if h.Cipher == "" {
+			break
+		}
+		if _, ok := ciphers[h.Cipher]; !ok {
+			return nil, fmt.Errorf("unsupported cipher:%s", h.Cipher)
+		}
+		if o.security && conf.Security != h.Cipher {
+			return nil, fmt.Errorf("--security %s does not match cipher %s recorded in header", conf.Security, h.Cipher)
+		}
+		ff.security = h.Cipher
+	default:
+		return nil, fmt.Errorf("unsupported format:%s", h.Format)
+	}
+	ff.format = h.Format
+	return ff, nil
 }
 
-func DecData(cmd *cobra.Command, args []string) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("[FATA] DecData err:%s", err)
-			return
+// authenticate 解出文件密钥并校验文件头 MAC, 校验失败时返回 header.ErrBadMAC.
+// 版本 7 之前的文件头没有 MAC, 只给出提示; 新文件的文件头被降级时, 加密数据的密钥与之不匹配, 解密失败.
+func (o *decOptions) authenticate(br *bufio.Reader, ff *fileFormat) error {
+	h := ff.h
+	var key []byte
+	var err error
+	switch h.Format {
+	case header.FormatStream:
+		if key, err = streamKey(h, o.id); err != nil {
+			return err
+		}
+		ff.key = key
+	case header.FormatStandard:
+		if !h.Authenticated() {
+			break
+		}
+		if ff.keyData, err = o.standardKey(br); err != nil {
+			return err
 		}
-		if err := moveOutputFile(conf.File + ".dec"); err != nil {
-			log.Printf("[ERROR] moveOutputFile failed: %v output:%s ", err, conf.Out)
+		if key, _, err = utils.SplitKeyData(ff.keyData); err != nil {
+			return err
 		}
-	}()
+	default:
+		return fmt.Errorf("unsupported format:%s", h.Format)
+	}
+	if !h.Authenticated() {
+		log.Printf("[INFO] header version %d is not authenticated, re-encrypt the file to protect its header", h.Version)
+		return nil
+	}
+	return h.Verify(key)
+}
 
-	// read from file
-	priKey, err := os.ReadFile(strings.TrimPrefix(conf.PrivateKey, "@"))
+// standardKey 读取 standard 格式加密数据开头 RSA 加密的文件密钥材料并用私钥解密, 不消耗 br 中的数据.
+// 格式: 密钥长度(2, 小端) | RSA(密钥材料 + 0 + iv)
+func (o *decOptions) standardKey(br *bufio.Reader) ([]byte, error) {
+	if o.priKey == nil {
+		return nil, errors.New("standard format requires --private-key")
+	}
+	head, err := br.Peek(2)
 	if err != nil {
-		log.Fatalf("[FATA] Could not read private key file:%s", err)
+		return nil, err
 	}
-	if err := decFile(conf.File, priKey, md5.New(), utils.InitDecCipher(&conf)); err != nil {
-		log.Printf("[ERROR] Could not decrypt file:%s, err:%v", conf.File, err)
+	n := int(head[0]) | int(head[1])<<8
+	head, err = br.Peek(2 + n)
+	if err != nil {
+		return nil, err
 	}
+	return EncryptionFile.RsaDecrypt(o.priKey, head[2:])
 }
 
-func decFile(f string, priKey []byte, h hash.Hash, dec EncryptionFile.DecCipher) error {
-	fr, err := os.Open(f)
+// decryptPayload 解密 br 中文件头之后的数据写入 w, 文件头记录了压缩算法时解压, 文件带签名时验证明文的签名.
+// 签名在全部明文写出后才能验证.
+func (o *decOptions) decryptPayload(br *bufio.Reader, w io.Writer, ff *fileFormat) error {
+	out := w
+	var sig *header.Signature
+	if ff.h != nil {
+		sig = ff.h.Signature
+	}
+	if sig == nil && o.signerKey != nil {
+		return utils.ErrNoSignature
+	}
+	var digest hash.Hash
+	if sig != nil {
+		var err error
+		if digest, err = utils.SignatureHash(sig.Algorithm); err != nil {
+			return err
+		}
+		w = io.MultiWriter(w, digest)
+	}
+	// 元数据块位于明文开头, 不属于签名的内容
+	var ms *utils.MetadataSplitter
+	if ff.h != nil && ff.h.Metadata {
+		ms = &utils.MetadataSplitter{W: w}
+		w = ms
+	}
+	var dw io.WriteCloser
+	if ff.h != nil && ff.h.Compression != "" {
+		var err error
+		if dw, err = utils.NewDecompressWriter(w, ff.h.Compression); err != nil {
+			return err
+		}
+		w = dw
+	}
+
+	var err error
+	if ff.format == formatStream {
+		if ff.h == nil {
+			return header.ErrNoHeader
+		}
+		err = decStreamFile(br, w, ff.key, ff.h.MAC())
+	} else {
+		var h hash.Hash
+		if h, err = headerHash(ff.h); err != nil {
+			return err
+		}
+		c := conf
+		c.Security = ff.security
+		dec := utils.InitDecCipher(&c)
+		if ff.h != nil && ff.h.Authenticated() {
+			dec = utils.NewDecCipher(ff.security, ff.h.MAC())
+		}
+		err = decFile(br, w, o.priKey, h, dec)
+	}
+	if dw != nil {
+		if cerr := dw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if ms != nil && err == nil {
+		err = ms.Close()
+	}
 	if err != nil {
 		return err
 	}
-	defer fr.Close()
+	if ms != nil && conf.Preserve {
+		if mw, ok := out.(metadataWriter); ok {
+			mw.setMetadata(ms.Metadata)
+		} else {
+			log.Printf("[INFO] metadata of %s is not restored when writing to stdout", ms.Metadata.Name)
+		}
+	}
+	if sig == nil {
+		if !o.quiet {
+			log.Printf("[INFO] file is not signed")
+		}
+		return nil
+	}
 
-	fw, err := os.Create(f + ".dec")
+	// 文件头中嵌入的公钥由加密者写入, 只能说明签名与之一致, 不能证明签名者身份
+	signerKey := o.signerKey
+	if signerKey == nil {
+		signerKey = sig.PublicKey
+	}
+	if err := utils.VerifySignature(signerKey, sig, digest.Sum(nil)); err != nil {
+		return err
+	}
+	fp, err := utils.Fingerprint(signerKey)
 	if err != nil {
 		return err
 	}
-	defer fw.Close()
+	if o.quiet {
+		return nil
+	}
+	if o.signerKey != nil {
+		log.Printf("[INFO] signature verified, signed by --signer %s (%s)", fp, sig.Algorithm)
+	} else {
+		log.Printf("[INFO] signature matches the public key %s (%s) embedded in the file, signer is NOT authenticated, use --signer to check who signed it", fp, sig.Algorithm)
+	}
+	return nil
+}
+
+// decFile 解密 EncryptionFile 格式的数据, h 为自校验哈希
+func decFile(r io.Reader, w io.Writer, priKey []byte, h hash.Hash, dec EncryptionFile.DecCipher) error {
+	return EncryptionFile.DecData(r, w, priKey, h, dec)
+}
+
+// peekHeader 从 br 读取文件头, 不以魔数开头(旧版本生成的无文件头文件)时返回 nil, 且不消耗数据
+func peekHeader(br *bufio.Reader) (*header.Header, error) {
+	magic, err := br.Peek(len(header.Magic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if string(magic) != header.Magic {
+		return nil, nil
+	}
+	return header.Read(br)
+}
 
-	h.Reset()
-	return EncryptionFile.DecData(fr, fw, priKey, h, dec)
+// headerHash 返回 standard 格式的自校验哈希, 旧版本生成的无文件头文件(h 为 nil)使用 MD5
+func headerHash(h *header.Header) (hash.Hash, error) {
+	if h == nil {
+		return utils.NewHash(utils.HashMD5)
+	}
+	if h.Format != header.FormatStandard {
+		return nil, fmt.Errorf("unsupported format:%s", h.Format)
+	}
+	return utils.NewHash(h.Hash)
 }
@@ -4,19 +4,16 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"bufio"
-	"crypto/aes"
-	"crypto/md5"
-	"crypto/rand"
-	"fmt"
+	"bytes"
+	"errors"
 	"github.com/jan-bar/EncryptionFile"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go-crypto/crypto-cli/header"
 	"go-crypto/crypto-cli/utils"
-	"hash"
 	"io"
 	"log"
 	"os"
-	"reflect"
 )
 
 // encryptCmd represents the encrypt command
@@ -29,8 +26,25 @@ var encryptCmd = &cobra.Command{
 crypto-cli encrypt --public-key public.key -f your-src.file -o ciphered.file
 crypto-cli encrypt -g -f your.file -o ciphered.file
 crypto-cli encrypt --public-key public.key --security aes-256-cbc -f your.file -o ciphered.file
+crypto-cli encrypt --public-key public.key --format stream -f your.file -o ciphered.file
+crypto-cli encrypt --passphrase -f your.file -o ciphered.file
+crypto-cli encrypt --recipient alice.key --recipient bob.key --recipients-file team.keys -f your.file -o ciphered.file
+crypto-cli encrypt --public-key x25519.public.key -f your.file -o ciphered.file
+crypto-cli encrypt -g --key-type x25519 -f your.file -o ciphered.file
+crypto-cli encrypt --public-key archive.public.key -f your.file -o ciphered.file   archive 为 mlkem768x25519 混合公钥
+crypto-cli encrypt --public-key public.key --sign-key signing.private.key -f your.file -o ciphered.file
+crypto-cli encrypt --public-key public.key -r --include '*.log' --exclude tmp -f logs -o logs.enc   加密目录下的文件, 保留相对路径
+crypto-cli encrypt --public-key public.key --jobs 8 -o encrypted a.csv b.csv c.csv   并发加密多个文件, 输出到 encrypted 目录
+crypto-cli encrypt --public-key public.key --compress zstd -f app.log -o app.log.enc   压缩后加密, 解密时自动解压
+crypto-cli encrypt --public-key public.key --preserve -f app.conf -o app.conf.enc   同时加密存储文件名, 权限, 时间与扩展属性
+crypto-cli encrypt --public-key public.key --verify --private-key private.key -f your.file   加密后使用私钥解密校验, 通过后才覆盖原文件
+crypto-cli encrypt --public-key public.key --shred -f secret.txt -o secret.txt.enc   加密并校验通过后粉碎原文件(shred 隐含 verify)
+crypto-cli encrypt --public-key public.key --archive data --compress zstd -o backup.enc   将目录归档压缩后加密为单个文件
+pg_dump db | crypto-cli encrypt --public-key public.key -f - -o - > db.sql.enc   从标准输入读取, 写到标准输出
 `,
-	//PreRun: initEncryptor,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		Validate(args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Println("[INFO] EncData called")
 		EncData(cmd, args)
@@ -50,153 +64,254 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// encryptCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	encryptCmd.Flags().String("archive", "", `将指定目录以 tar 格式归档后加密为单个文件, 隐藏文件名与文件大小, 必须指定 out, 不能与 file 同时使用
+include/exclude 规则同 recursive, 只归档普通文件, 不支持 sign-key`)
+	encryptCmd.Flags().String("compress", utils.CompressNone, `加密前的压缩算法, 记录于文件头, 解密时自动解压, 默认 none
+支持 none gzip zstd; 压缩后的文件不支持 decrypt --range`)
+	encryptCmd.Flags().Bool("verify", false, `加密完成后, 替换原文件/目标文件之前重新读取加密结果, 校验文件头与完整性(认证标签/自校验哈希), 校验失败时不修改原文件
+同时指定 private-key 时使用私钥完整解密, 并比对明文摘要`)
+	encryptCmd.Flags().Bool("shred", false, `加密成功(输出已同步到磁盘并校验)后粉碎原文件: 以随机数据覆盖并同步, 截断后删除
+隐含 verify, 加密结果校验通过后才粉碎, 批量加密时逐个文件校验
+不填 out 时原文件已被加密结果替换, 覆盖的是被替换的原数据; 原文件有多个硬链接时不粉碎
+写时复制(btrfs, zfs), 日志结构(f2fs), 网络文件系统, overlayfs 及 SSD 上只能尽力而为, 会给出提示`)
+	encryptCmd.Flags().Int("shred-passes", 3, `shred 时随机数据覆盖的遍数, 默认 3`)
+
+	viper.BindPFlags(encryptCmd.Flags())
 }
 
-func encrypt(cmd *cobra.Command, args []string) {
-	defer func() {
-		if err := moveOutputFile("ciphered-file.bin"); err != nil {
-			log.Printf("[ERROR] moveOutputFile failed: %v output:%s ", err, conf.Out)
+func EncData(cmd *cobra.Command, args []string) {
+	opts := newEncOptions()
+	if len(inputs) > 0 {
+		if failed := runBatch(".enc", opts.encrypt); failed > 0 {
+			os.Exit(1)
 		}
-	}()
-	in, err := os.Open(conf.File)
-	if err != nil {
-		log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+		return
+	}
+
+	cleanupOnInterrupt()
+	if conf.Archive != "" {
+		err := writeOutput(".enc", func(w io.Writer) error {
+			return opts.encryptArchive(conf.Archive, w)
+		})
+		if err != nil {
+			log.Fatalf("[FATA] archive dir:%s err:%s", conf.Archive, err)
+		}
+		return
 	}
-	defer in.Close()
 
-	out, err := os.OpenFile("ciphered-file.bin", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	in, err := openInput(conf.File)
 	if err != nil {
 		log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
 	}
-	defer out.Close()
-
-	ivBuffer := bufio.NewWriter(out)
-	ivBuffer.Write(encryptor.GetIV())
-	ivBuffer.WriteString(cipherBlockSep)
-	if err := ivBuffer.Flush(); err != nil {
-		log.Fatalf("[FATA] write iv into cipher file:%s err:%s", conf.File, err)
+	sf, err := openShred(conf.File)
+	if err != nil {
+		log.Fatalf("[FATA] open file:%s for shred err:%s", conf.File, err)
 	}
+	err = writeOutput(".enc", func(w io.Writer) error {
+		// 覆盖原文件前关闭输入
+		defer in.Close()
+		return opts.encrypt(in, w)
+	})
+	if err != nil {
+		log.Fatalf("[FATA] encrypt file:%s err:%s, the original file is unchanged", conf.File, err)
+	}
+	if sf != nil {
+		if err := shredFile(sf, conf.Out == ""); err != nil {
+			log.Fatalf("[FATA] file:%s is encrypted, but shred failed: %s", conf.File, err)
+		}
+	}
+}
 
-	// 每 100 mb 加密一次
-	fInfo, _ := in.Stat()
-	fLen := fInfo.Size()
-	fmt.Println("待处理文件大小:", fLen)
-	maxLen := 1024 * 1024 * 100 //100mb  每 100mb 进行加密一次
-	var forNum int64 = 0
-	getLen := fLen
+// encOptions 加密参数, 由命令行参数一次性解析, 所有文件共用
+type encOptions struct {
+	recipients []utils.Recipient
+	// pubKey standard 格式使用的 RSA 公钥, 为空时使用 stream 格式
+	pubKey []byte
+	signer *utils.Signer
+	// verify 指定 --verify 与 --private-key 时用于完整解密校验加密结果
+	verify *decOptions
+}
 
-	if fLen > int64(maxLen) {
-		getLen = int64(maxLen)
-		forNum = fLen / int64(maxLen)
-		fmt.Println("需要加密次数：", forNum+1)
+// newEncOptions 读取口令/公钥/签名私钥, 并确定加密格式, 参数错误时直接退出
+func newEncOptions() *encOptions {
+	opts := &encOptions{}
+	if conf.Preserve && conf.File == stdio {
+		log.Fatalf("[FATA] --preserve does not support stdin")
 	}
-
-	cipherBuf := make([]byte, getLen)
-	for i := 0; i < int(forNum+1); i++ {
-		n, err := in.Read(cipherBuf)
-		if err == io.EOF {
-			break
-		}
+	if conf.Shred && (conf.File == stdio || conf.Out == stdio) {
+		log.Fatalf("[FATA] --shred does not support stdin or stdout")
+	}
+	if conf.Shred && conf.ShredPasses < 1 {
+		log.Fatalf("[FATA] invalid shred passes:%d", conf.ShredPasses)
+	}
+	// 粉碎后原文件无法恢复, 必须先确认加密结果可用, 批量加密时对每个文件同样生效
+	if conf.Shred && !conf.Verify {
+		log.Printf("[INFO] --shred implies --verify")
+		conf.Verify = true
+	}
+	if conf.Verify && (conf.Out == stdio || conf.File == stdio && conf.Out == "") {
+		log.Fatalf("[FATA] --verify does not support stdout")
+	}
+	if conf.Verify && conf.PrivateKey != "" {
+		priKey, err := utils.ReadPrivateKey(conf.PrivateKey, conf.KeyPassphraseFile)
 		if err != nil {
-			log.Fatalf("[FATA] read file err:%s", err)
+			log.Fatalf("[FATA] Could not read private key file:%s", err)
 		}
-		ciphertext, err := encryptor.Encrypt(cipherBuf[:n])
-		if err != nil {
-			log.Fatalf("[FATA] encrypt error: %v", err)
+		opts.verify = &decOptions{priKey: priKey}
+		if opts.verify.id, err = utils.NewIdentity(priKey); err != nil {
+			log.Fatalf("[FATA] invalid private key:%s", err)
 		}
-
-		plaintext, err := encryptor.Decrypt(ciphertext)
+	}
+	if conf.SignKey != "" {
+		// 签名需要先读取一遍明文计算摘要
+		if conf.File == stdio {
+			log.Fatalf("[FATA] --sign-key does not support stdin")
+		}
+		priKey, err := utils.ReadPrivateKey(conf.SignKey, conf.KeyPassphraseFile)
 		if err != nil {
-			log.Fatalf("[FATA] decrypt error: %v", err)
+			log.Fatalf("[FATA] Could not read sign key file:%s", err)
 		}
-		if !reflect.DeepEqual(plaintext, cipherBuf) {
-			log.Fatalf("[FATA] decrypt error: not equal original")
+		if opts.signer, err = utils.NewSigner(priKey); err != nil {
+			log.Fatalf("[FATA] invalid sign key:%s", err)
 		}
-		log.Printf("[INFO] encrypted plaintext: %#v", plaintext)
+	}
 
-		//换行处理，有点乱了，想到更好的再改
-		//写入
-		buf := bufio.NewWriter(out)
-		buf.Write(ciphertext)
-		buf.WriteString(cipherBlockSep)
-		if err := buf.Flush(); err != nil {
-			log.Fatalf("[FATA] write ciphertext into cipher file error: %v", err)
+	if conf.Passphrase {
+		pass, err := utils.ReadPassphrase(conf.PassphraseFile, utils.PassphraseEnv, "请输入口令", true)
+		if err != nil {
+			log.Fatalf("[FATA] read passphrase error:%s", err)
 		}
+		opts.recipients = []utils.Recipient{&utils.PassphraseRecipient{KDF: conf.KDF, Cost: conf.KDFCost, Passphrase: pass}}
+		return opts
 	}
-}
 
-func initEncryptor(cmd *cobra.Command, args []string) {
-	encryptor = utils.InitAesEncryptor(&conf)
+	var paths []string
+	if conf.PublicKey != "" {
+		paths = append(paths, conf.PublicKey)
+	}
+	paths = append(paths, conf.Recipients...)
+	pubKeys, err := utils.ReadPublicKeys(paths, conf.RecipientsFile)
+	if err != nil {
+		log.Fatalf("[FATA] read public key error:%s", err)
+	}
+	if conf.GenerateKey {
+		pubKey, _, err := utils.GenKey(conf.KeyType)
+		if err != nil {
+			log.Fatalf("[FATA] GenKey error:%s", err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	if len(pubKeys) == 0 {
+		log.Fatalf("[FATA] --generate-key, --public-key, --recipient or --recipients-file must Specify one")
+	}
 
-	// The IV needs to be unique, but not secure. Therefore it's common to
-	// include it at the beginning of the ciphertext.
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-		log.Fatalf("[FATA] init encrypt error: %v", err)
+	for _, pubKey := range pubKeys {
+		recipient, err := utils.NewRecipient(pubKey)
+		if err != nil {
+			log.Fatalf("[FATA] invalid public key error:%s", err)
+		}
+		opts.recipients = append(opts.recipients, recipient)
 	}
-	encryptor.SetIV(iv)
-	fmt.Println("Encrypt iv: ", iv)
-}
 
-func moveOutputFile(tmp string) error {
-	if conf.Out != "" {
-		return os.Rename(tmp, conf.Out)
+	// standard 格式只能使用一个 RSA 公钥包装文件密钥且不支持签名, 多个接收者, 其他类型的密钥或签名时使用 stream 格式
+	if _, rsa := opts.recipients[0].(*utils.RSARecipient); conf.Format == formatStream || len(opts.recipients) > 1 || !rsa || opts.signer != nil {
+		if conf.Format != formatStream {
+			log.Printf("[INFO] %d recipients, signed:%t, using stream format", len(opts.recipients), opts.signer != nil)
+		}
+		return opts
 	}
-	return os.Rename(tmp, conf.File)
+	opts.pubKey = pubKeys[0]
+	return opts
 }
 
-func EncData(cmd *cobra.Command, args []string) {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("[FATA] EncData err:%s", err)
-			return
+// encrypt 加密 r 写入 w, 指定签名私钥时先对明文签名, 指定 --preserve 时在明文前加入原始文件元数据, 指定 --compress 时先压缩再加密
+func (o *encOptions) encrypt(r io.Reader, w io.Writer) error {
+	hdr := &header.Header{Compression: compression(), Metadata: conf.Preserve}
+	if o.signer != nil {
+		var err error
+		if hdr.Signature, err = signInput(r, o.signer); err != nil {
+			return err
 		}
-		if err := moveOutputFile(conf.File + ".enc"); err != nil {
-			log.Printf("[ERROR] moveOutputFile failed: %v output:%s ", err, conf.Out)
+	}
+	var meta bytes.Buffer
+	if hdr.Metadata {
+		f, ok := r.(interface{ Name() string })
+		if !ok {
+			return errors.New("--preserve requires a file input")
 		}
-	}()
-
-	if !conf.GenerateKey && conf.PublicKey == "" {
-		log.Fatalf("[FATA] --generate-key or --key-file must Specify one")
-		return
+		m, err := utils.ReadMetadata(f.Name())
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteMetadata(&meta, m); err != nil {
+			return err
+		}
+	}
+	v := newVerifier()
+	if v != nil {
+		r = io.TeeReader(r, v.plain)
 	}
+	if hdr.Metadata {
+		r = io.MultiReader(&meta, r)
+	}
+	if hdr.Compression != "" {
+		cr, err := utils.NewCompressReader(r, hdr.Compression)
+		if err != nil {
+			return err
+		}
+		// 加密失败时结束压缩 goroutine
+		defer cr.Close()
+		r = cr
+	}
+	return o.encryptHeader(r, w, hdr, v)
+}
 
-	var pubKey []byte
+// encryptHeader 加密 r 写入 w, hdr 为预先设置了签名/归档/压缩等字段的文件头.
+// v 不为空(指定了 --verify)时加密完成后重新读取 w 校验.
+func (o *encOptions) encryptHeader(r io.Reader, w io.Writer, hdr *header.Header, v *verifier) error {
+	if v != nil {
+		r = io.TeeReader(r, v.payload)
+	}
+	var key []byte
 	var err error
-	if conf.PublicKey != "" {
-		pubKey, err = os.ReadFile(conf.PublicKey)
-		if err != nil {
-			log.Fatalf("[FATA] read public key error:%s, key:%s", err, conf.PublicKey)
+	if o.pubKey == nil {
+		if key, err = newFileKey(); err != nil {
+			return err
 		}
+		err = encStreamFile(r, w, hdr, o.recipients, key)
 	} else {
-		pubKey, _, err = utils.GenRsaKey()
-		if err != nil {
-			log.Fatalf("[FATA] GenRsaKey error:%s", err)
+		if key, err = utils.NewKeyMaterial(); err != nil {
+			return err
 		}
+		err = encFile(r, w, hdr, o.pubKey, conf.Security, conf.Hash, key)
 	}
-
-	if err := encFile(conf.File, pubKey, md5.New(), utils.InitEncCipher(&conf)); err != nil {
-		log.Printf("[ERROR] EncryptionFile EncData err:%s", err)
+	if v != nil {
+		v.key = key
 	}
-
-	//if err := decFile(conf.File, priKey, md5.New(), utils.InitDecCipher(&conf)); err != nil {
-	//	log.Printf("[ERROR] Could not decrypt file:%s, err:%v", conf.File, err)
-	//}
+	if err != nil || v == nil {
+		return err
+	}
+	return v.check(w, hdr, o.verify)
 }
 
-func encFile(f string, pubKey []byte, h hash.Hash, enc EncryptionFile.EncCipher) error {
-	fr, err := os.Open(f)
+// encFile 使用 EncryptionFile 格式加密 r 写入 w, 文件头 hdr 记录接收者指纹, 加密算法 security 与自校验哈希 hashName.
+// material 为文件密钥材料, 用于文件头 MAC, 并与 MAC 一同派生加密数据的密钥, 见 utils.NewEncCipher
+func encFile(r io.Reader, w io.Writer, hdr *header.Header, pubKey []byte, security, hashName string, material []byte) error {
+	h, err := utils.NewHash(hashName)
 	if err != nil {
 		return err
 	}
-	defer fr.Close()
-
-	fw, err := os.Create(f + ".enc")
+	fp, err := utils.Fingerprint(pubKey)
 	if err != nil {
 		return err
 	}
-	defer fw.Close()
-
-	h.Reset()
-	return EncryptionFile.EncData(fr, fw, pubKey, h, enc)
+	hdr.Format = header.FormatStandard
+	hdr.Recipients = []header.Stanza{{Type: header.StanzaRSA, Fingerprint: fp}}
+	hdr.Cipher = security
+	hdr.Hash = hashName
+	hdr.Version = header.Version
+	if err := header.Write(w, hdr, material); err != nil {
+		return err
+	}
+	return EncryptionFile.EncData(r, w, pubKey, h, utils.NewEncCipher(security, material, hdr.MAC()))
 }
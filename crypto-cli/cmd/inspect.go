@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "查看加密文件信息",
+	Long: `解析加密文件头, 显示格式版本, 加密算法, 文件密钥包装方式, 接收者公钥指纹, 自校验哈希, 签名者, 目录归档与压缩算法, 是否保存了原始文件元数据, 加密数据长度, 无需私钥.
+示例:
+
+crypto-cli inspect -f ciphered.file
+crypto-cli inspect -f ciphered.file --json`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf.File == "" {
+			log.Fatalf("[FATA] required flag \"file\" not set")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		info, err := inspectFile(conf.File)
+		if err != nil {
+			log.Fatalf("[FATA] inspect file:%s err:%s", conf.File, err)
+		}
+		if conf.JSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(info); err != nil {
+				log.Fatalf("[FATA] encode json err:%s", err)
+			}
+			return
+		}
+		info.print(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
+
+// fileInfo 加密文件信息
+type fileInfo struct {
+	File string `json:"file"`
+	// Version 文件头版本, 旧版本生成的无文件头文件为 0
+	Version     int             `json:"version"`
+	Format      string          `json:"format"`
+	Cipher      string          `json:"cipher,omitempty"`
+	Hash        string          `json:"hash,omitempty"`
+	Archive     string          `json:"archive,omitempty"`
+	Compression string          `json:"compression,omitempty"`
+	Metadata    bool            `json:"metadata,omitempty"`
+	Recipients  []recipientInfo `json:"recipients"`
+	Signature   *signatureInfo  `json:"signature,omitempty"`
+	HeaderSize  int64           `json:"header_size"`
+	PayloadSize int64           `json:"payload_size"`
+}
+
+// recipientInfo 一个接收者的文件密钥包装方式
+type recipientInfo struct {
+	Type        string      `json:"type"`
+	Fingerprint string      `json:"fingerprint,omitempty"`
+	KDF         *header.KDF `json:"kdf,omitempty"`
+}
+
+type signatureInfo struct {
+	Algorithm   string `json:"alg"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// inspectFile 读取文件头, 没有文件头的文件视为旧版本生成的 standard 格式
+func inspectFile(f string) (*fileInfo, error) {
+	fr, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+	stat, err := fr.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &fileInfo{File: f}
+	ok, err := header.Detect(fr)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// 旧版本 EncryptionFile 格式: 文件密钥由 RSA 加密, 加密算法未记录, 自校验哈希为 MD5
+		info.Format = header.FormatStandard
+		info.Hash = utils.HashMD5
+		info.Recipients = []recipientInfo{{Type: header.StanzaRSA}}
+		info.PayloadSize = stat.Size()
+		return info, nil
+	}
+
+	if _, err := fr.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h, err := header.Read(fr)
+	if err != nil {
+		return nil, err
+	}
+	if info.HeaderSize, err = fr.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	info.Version = h.Version
+	info.Format = h.Format
+	info.Cipher = h.Cipher
+	if info.Cipher == "" && h.Format == header.FormatStream {
+		// 早期的 stream 格式文件头未记录加密算法, 固定为 AES-256-GCM
+		info.Cipher = streamCipher
+	}
+	info.Hash = h.Hash
+	info.Archive = h.Archive
+	info.Compression = h.Compression
+	info.Metadata = h.Metadata
+	info.PayloadSize = stat.Size() - info.HeaderSize
+	for _, s := range h.Recipients {
+		info.Recipients = append(info.Recipients, recipientInfo{Type: s.Type, Fingerprint: s.Fingerprint, KDF: s.KDF})
+	}
+	if h.Signature != nil {
+		info.Signature = &signatureInfo{Algorithm: h.Signature.Algorithm, Fingerprint: h.Signature.Fingerprint}
+	}
+	return info, nil
+}
+
+func (i *fileInfo) print(w io.Writer) {
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "未记录"
+		}
+		return s
+	}
+	fmt.Fprintf(w, "文件: %s\n", i.File)
+	if i.Version == 0 {
+		fmt.Fprintf(w, "版本: 无文件头(旧版本)\n")
+	} else {
+		fmt.Fprintf(w, "版本: %d\n", i.Version)
+	}
+	fmt.Fprintf(w, "格式: %s\n", i.Format)
+	fmt.Fprintf(w, "加密算法: %s\n", orUnknown(i.Cipher))
+	if i.Format == header.FormatStandard {
+		fmt.Fprintf(w, "自校验哈希: %s\n", orUnknown(i.Hash))
+	}
+	if i.Archive != "" {
+		fmt.Fprintf(w, "目录归档: %s\n", i.Archive)
+	}
+	if i.Compression != "" {
+		fmt.Fprintf(w, "压缩算法: %s\n", i.Compression)
+	}
+	if i.Metadata {
+		fmt.Fprintf(w, "元数据: 已保存\n")
+	}
+	fmt.Fprintf(w, "接收者: %d\n", len(i.Recipients))
+	for _, r := range i.Recipients {
+		params := []string{r.Type}
+		if r.Fingerprint != "" {
+			params = append(params, r.Fingerprint)
+		}
+		if kdf := r.KDF; kdf != nil {
+			if kdf.LogN > 0 {
+				params = append(params, fmt.Sprintf("logN=%d r=%d p=%d", kdf.LogN, kdf.R, kdf.P))
+			} else {
+				params = append(params, fmt.Sprintf("time=%d memory=%dKiB threads=%d", kdf.Time, kdf.Memory, kdf.Threads))
+			}
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(params, " "))
+	}
+	if i.Signature != nil {
+		fmt.Fprintf(w, "签名: %s %s\n", i.Signature.Algorithm, i.Signature.Fingerprint)
+	}
+	fmt.Fprintf(w, "文件头长度: %d\n", i.HeaderSize)
+	fmt.Fprintf(w, "加密数据长度: %d\n", i.PayloadSize)
+}
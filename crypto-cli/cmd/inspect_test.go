@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runInspect 执行 inspect, 返回标准输出
+func runInspect(t *testing.T, args ...string) []byte {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := cliCommand(t, append([]string{"inspect"}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if code := exitCode(t, cmd); code != 0 {
+		t.Fatalf("inspect exit code = %d, stderr:\n%s", code, stderr.String())
+	}
+	return stdout.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	dir := testFiles(t)
+	_, pubKey := utils.KeyPaths(dir, "")
+	fp, err := utils.Fingerprint(mustReadFile(t, pubKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := filepath.Join(dir, "plain.enc")
+	if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "--compress", "gzip", "-f", filepath.Join(dir, "plain"), "-o", enc); code != 0 {
+		t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+	}
+	legacy := filepath.Join(dir, "legacy.enc")
+	if err := os.WriteFile(legacy, bytes.Repeat([]byte{0x5a}, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want fileInfo
+		text []string
+	}{
+		{
+			name: "header",
+			file: enc,
+			want: fileInfo{
+				File: enc, Version: header.Version, Format: header.FormatStream, Cipher: streamCipher, Compression: utils.CompressGzip,
+				Recipients: []recipientInfo{{Type: header.StanzaX25519, Fingerprint: fp}},
+			},
+			text: []string{"版本: 7", "格式: stream", "加密算法: " + streamCipher, "压缩算法: gzip", "接收者: 1", "  x25519 " + fp},
+		},
+		{
+			name: "legacy",
+			file: legacy,
+			want: fileInfo{
+				File: legacy, Format: header.FormatStandard, Hash: utils.HashMD5,
+				Recipients: []recipientInfo{{Type: header.StanzaRSA}}, PayloadSize: 100,
+			},
+			text: []string{"版本: 无文件头(旧版本)", "格式: standard", "加密算法: 未记录", "自校验哈希: md5", "加密数据长度: 100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := string(runInspect(t, "-f", tt.file))
+			for _, want := range tt.text {
+				if !strings.Contains(text, want+"\n") {
+					t.Errorf("inspect output does not contain %q:\n%s", want, text)
+				}
+			}
+
+			var got fileInfo
+			if err := json.Unmarshal(runInspect(t, "-f", tt.file, "--json"), &got); err != nil {
+				t.Fatalf("inspect --json is not valid json: %v", err)
+			}
+			if got.Version != tt.want.Version || got.Format != tt.want.Format || got.Cipher != tt.want.Cipher ||
+				got.Hash != tt.want.Hash || got.Compression != tt.want.Compression {
+				t.Errorf("inspect --json = %+v, want %+v", got, tt.want)
+			}
+			if len(got.Recipients) != len(tt.want.Recipients) || got.Recipients[0] != tt.want.Recipients[0] {
+				t.Errorf("inspect --json recipients = %+v, want %+v", got.Recipients, tt.want.Recipients)
+			}
+			if tt.want.PayloadSize != 0 && got.PayloadSize != tt.want.PayloadSize {
+				t.Errorf("inspect --json payload_size = %d, want %d", got.PayloadSize, tt.want.PayloadSize)
+			}
+		})
+	}
+}
+
+func mustReadFile(t *testing.T, f string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
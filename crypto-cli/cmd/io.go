@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// stdio 作为输入/输出文件名时表示标准输入/标准输出
+const stdio = "-"
+
+// nopCloser 关闭时不关闭标准输入/标准输出
+type nopCloser struct {
+	*os.File
+}
+
+func (nopCloser) Close() error { return nil }
+
+// openInput 打开输入文件, "-" 表示标准输入
+func openInput(f string) (io.ReadCloser, error) {
+	if f == stdio {
+		return nopCloser{os.Stdin}, nil
+	}
+	return os.Open(f)
+}
+
+// output 加密/解密结果的输出.
+// 写到文件时先写入目标目录下的临时文件, 由 finish 同步到磁盘, 校验长度后原子地重命名为目标文件;
+// 出错时由 remove 删除临时文件, 目标文件(包括被覆盖的原文件)保持不变. 写到标准输出时 tmp 为空.
+type output struct {
+	io.WriteCloser
+	tmp    string
+	target string
+	// n 已写入的字节数
+	n int64
+	// meta 解密出的原始文件元数据, finish 时恢复到输出文件
+	meta *utils.Metadata
+	// rename 为 true 时输出文件使用元数据中的原始文件名
+	rename bool
+}
+
+// metadataWriter 可恢复原始文件元数据的输出
+type metadataWriter interface {
+	setMetadata(m *utils.Metadata)
+}
+
+// setMetadata 记录解密出的原始文件元数据, 输出到目录时使用原始文件名
+func (o *output) setMetadata(m *utils.Metadata) {
+	if o.tmp == "" {
+		return
+	}
+	o.meta = m
+	// 文件名来自加密数据, 只接受不含路径的文件名
+	if name := m.Name; o.rename && name != "" && name == filepath.Base(name) && filepath.IsLocal(name) {
+		o.target = filepath.Join(filepath.Dir(o.target), name)
+	}
+}
+
+// newOutput 创建单个文件的输出, --out 为 "-", 或输入为标准输入且未指定 --out 时写到标准输出,
+// 否则写到临时文件, 完成后移动到 --out 或覆盖原文件
+func newOutput(suffix string) (*output, error) {
+	if conf.Out == stdio || (conf.File == stdio && conf.Out == "") {
+		return &output{WriteCloser: nopCloser{os.Stdout}}, nil
+	}
+	target := conf.Out
+	if target == "" {
+		target = conf.File
+	}
+	// --preserve 且输出到目录时, 输出文件默认与输入文件同名, 解密出元数据后使用原始文件名
+	if info, err := os.Stat(conf.Out); conf.Preserve && err == nil && info.IsDir() {
+		o, err := createOutput(filepath.Join(conf.Out, filepath.Base(conf.File)), suffix)
+		if err == nil {
+			o.rename = true
+		}
+		return o, err
+	}
+	return createOutput(target, suffix)
+}
+
+// writeOutput 创建单个文件的输出并调用 write 写入, 成功后原子地替换目标文件; 出错或 panic 时删除临时文件, 不修改目标文件
+func writeOutput(suffix string, write func(w io.Writer) error) error {
+	out, err := newOutput(suffix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			out.remove()
+			panic(p)
+		}
+	}()
+	if err := write(out); err != nil {
+		out.remove()
+		return err
+	}
+	return out.finish()
+}
+
+// createOutput 在 target 所在目录创建临时文件 .文件名+suffix-随机数, 完成后移动到 target.
+// target 已存在时临时文件沿用其权限, 否则为 0644.
+func createOutput(target, suffix string) (*output, error) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	}
+	fw, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+suffix+"-*")
+	if err != nil {
+		return nil, err
+	}
+	trackTemp(fw.Name())
+	o := &output{WriteCloser: fw, tmp: fw.Name(), target: target}
+	if err := fw.Chmod(mode); err != nil {
+		o.remove()
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	n, err := o.WriteCloser.Write(p)
+	o.n += int64(n)
+	return n, err
+}
+
+// finish 将临时文件同步到磁盘并关闭, 校验文件长度后重命名为目标文件. 失败时删除临时文件, 不修改目标文件
+func (o *output) finish() error {
+	if o.tmp == "" {
+		return o.Close()
+	}
+	err := o.commit()
+	if err != nil {
+		o.remove()
+	}
+	return err
+}
+
+func (o *output) commit() error {
+	fw := o.WriteCloser.(*os.File)
+	if err := fw.Sync(); err != nil {
+		return err
+	}
+	info, err := fw.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != o.n {
+		return fmt.Errorf("temp file:%s size %d, written %d", o.tmp, info.Size(), o.n)
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	if o.meta != nil {
+		// 恢复失败(如无权限设置扩展属性)不影响解密结果
+		if err := o.meta.Apply(o.tmp); err != nil {
+			log.Printf("[ERROR] restore metadata of %s err:%s", o.target, err)
+		}
+	}
+	if err := os.Rename(o.tmp, o.target); err != nil {
+		return err
+	}
+	untrackTemp(o.tmp)
+	syncDir(filepath.Dir(o.target))
+	return nil
+}
+
+// remove 关闭并删除临时文件, 用于出错或输出不可信时(如签名验证失败)
+func (o *output) remove() {
+	o.Close()
+	if o.tmp != "" {
+		os.Remove(o.tmp)
+		untrackTemp(o.tmp)
+	}
+}
+
+// syncDir 将目录同步到磁盘, 使重命名持久化, 不支持时(如 Windows)忽略
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	d.Close()
+}
+
+// temps 未完成的临时文件, 收到中断信号时删除
+var temps = struct {
+	sync.Mutex
+	files map[string]struct{}
+}{files: make(map[string]struct{})}
+
+func trackTemp(name string) {
+	temps.Lock()
+	temps.files[name] = struct{}{}
+	temps.Unlock()
+}
+
+func untrackTemp(name string) {
+	temps.Lock()
+	delete(temps.files, name)
+	temps.Unlock()
+}
+
+// cleanupOnInterrupt 收到 Ctrl-C 或 SIGTERM 时删除未完成的临时文件并退出, 用于单文件模式.
+// 批量模式由 runBatch 取消任务并删除临时文件.
+func cleanupOnInterrupt() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		temps.Lock()
+		for name := range temps.files {
+			os.Remove(name)
+		}
+		temps.Unlock()
+		log.Fatalf("[FATA] interrupted by %s, temp files removed", sig)
+	}()
+}
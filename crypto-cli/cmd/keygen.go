@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"go-crypto/crypto-cli/utils"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keygenCmd represents the keygen command
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "生成密钥对",
+	Long: `生成 RSA, X25519, ML-KEM-768 + X25519 混合密钥对或 Ed25519 签名密钥对, 私钥文件权限为 0600, 已存在同名密钥文件时拒绝覆盖, 除非指定 --force.
+示例:
+
+crypto-cli keygen
+crypto-cli keygen --bits 4096 --out-dir ~/.crypto-cli --name backup
+crypto-cli keygen --key-type x25519 --name backup
+crypto-cli keygen --key-type mlkem768x25519 --name archive
+crypto-cli keygen --key-type ed25519 --name signing
+crypto-cli keygen --name backup --force
+crypto-cli keygen --name backup --passphrase   私钥使用口令加密存储, 口令经 argon2id/scrypt 派生`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if _, ok := utils.KeyTypes[conf.KeyType]; !ok {
+			log.Fatalf("[FATA] invalid key type:%s", conf.KeyType)
+		}
+		if _, ok := utils.RsaKeyBits[conf.Bits]; conf.KeyType == utils.KeyTypeRSA && !ok {
+			log.Fatalf("[FATA] invalid rsa key bits:%d, must be 3072 or 4096", conf.Bits)
+		}
+		if _, ok := kdfs[conf.KDF]; conf.Passphrase && !ok {
+			log.Fatalf("[FATA] invalid kdf:%s", conf.KDF)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &utils.KeyOptions{
+			Type:    conf.KeyType,
+			Bits:    conf.Bits,
+			Dir:     conf.OutDir,
+			Name:    conf.Name,
+			Force:   conf.Force,
+			KDF:     conf.KDF,
+			KDFCost: conf.KDFCost,
+		}
+		if conf.Passphrase {
+			pass, err := utils.ReadPassphrase(conf.PassphraseFile, utils.KeyPassphraseEnv, "请输入私钥口令", true)
+			if err != nil {
+				log.Fatalf("[FATA] read passphrase error:%s", err)
+			}
+			opts.Passphrase = pass
+		}
+		pubKey, _, err := utils.GenKeyFiles(opts)
+		if err != nil {
+			log.Fatalf("[FATA] GenKey error:%s", err)
+		}
+		fingerprint, err := utils.Fingerprint(pubKey)
+		if err != nil {
+			log.Fatalf("[FATA] public key fingerprint error:%s", err)
+		}
+		priPath, pubPath := utils.KeyPaths(conf.OutDir, conf.Name)
+		fmt.Printf("私钥: %s\n公钥: %s\n指纹: %s\n", priPath, pubPath, fingerprint)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+
+	keygenCmd.Flags().Int("bits", utils.DefaultRsaKeyBits, `RSA 密钥长度, 支持 3072 4096, 其他类型密钥忽略此参数`)
+	keygenCmd.Flags().String("out-dir", ".", `密钥文件输出目录`)
+	keygenCmd.Flags().String("name", "", `密钥文件名前缀, 生成 NAME.private.key 与 NAME.public.key, 不填则为 private.key 与 public.key`)
+	keygenCmd.Flags().Bool("force", false, `密钥文件已存在时覆盖`)
+
+	viper.BindPFlags(keygenCmd.Flags())
+}
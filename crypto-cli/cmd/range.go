@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jan-bar/EncryptionFile"
+	"go-crypto/aes"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"os"
+	"strings"
+)
+
+// sizedReaderAt 已知明文长度的随机访问解密 Reader
+type sizedReaderAt interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// decRangeFile 只解密明文 [start, end) 范围内的数据, end < 0 表示直到结尾.
+// 支持 stream 格式, 以及 standard 格式的 CTR 模式; standard 格式不会校验整体 HASH.
+// ff 为 parse 已解析并校验的文件格式, off 为 fr 中文件头之后的偏移.
+func decRangeFile(fr *os.File, off int64, w io.Writer, ff *fileFormat, o *decOptions, start, end int64) error {
+	info, err := fr.Stat()
+	if err != nil {
+		return err
+	}
+	if ff.h != nil && ff.h.Compression != "" {
+		return fmt.Errorf("range is not supported by compressed file, compression:%s", ff.h.Compression)
+	}
+
+	var ra sizedReaderAt
+	if ff.format == formatStream {
+		ra, err = streamReaderAt(fr, off, info.Size(), ff)
+	} else {
+		ra, err = ctrReaderAt(fr, off, info.Size(), ff, o.priKey)
+	}
+	if err != nil {
+		return err
+	}
+	if ff.h != nil && ff.h.Metadata {
+		if ra, err = skipMetadata(ra); err != nil {
+			return err
+		}
+	}
+
+	if end < 0 || end > ra.Size() {
+		end = ra.Size()
+	}
+	if start > end {
+		start = end
+	}
+
+	_, err = io.Copy(w, io.NewSectionReader(ra, start, end-start))
+	return err
+}
+
+// ctrReaderAt 返回 EncryptionFile 格式 CTR 模式文件的随机访问解密 Reader, base 为文件头之后的偏移.
+// 文件格式: [文件头] | 密钥长度(2, 小端) | RSA(key + 0 + iv) | 密文 | HASH
+func ctrReaderAt(fr *os.File, base, size int64, ff *fileFormat, priKey []byte) (sizedReaderAt, error) {
+	if mode := ff.security[strings.LastIndex(ff.security, "-")+1:]; aes.Mode(strings.ToUpper(mode)) != aes.ModeCTR {
+		return nil, fmt.Errorf("range is only supported by stream format or ctr mode, security:%s", ff.security)
+	}
+	h, err := headerHash(ff.h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fr.Seek(base, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(fr, head); err != nil {
+		return nil, err
+	}
+	n := int64(head[0]) | int64(head[1])<<8
+	encKey := make([]byte, n)
+	if _, err := io.ReadFull(fr, encKey); err != nil {
+		return nil, err
+	}
+	// 版本 7 起 parse 校验文件头时已解出, 不再重复私钥运算
+	data := ff.keyData
+	if data == nil {
+		if data, err = EncryptionFile.RsaDecrypt(priKey, encKey); err != nil {
+			return nil, err
+		}
+	}
+	var e *aes.Encryptor
+	if ff.h != nil && ff.h.Authenticated() {
+		// 版本 7 起加密数据的密钥由密钥材料与头部 MAC 派生
+		if e, err = utils.CTRPayloadEncryptor(data, ff.h.MAC()); err != nil {
+			return nil, err
+		}
+	} else {
+		key, iv, ok := bytes.Cut(data, []byte{0})
+		if !ok {
+			return nil, errors.New("invalid key data")
+		}
+		e = aes.NewEncryptor(key, aes.ModeCTR)
+		if err := e.SetIV(iv); err != nil {
+			return nil, err
+		}
+	}
+	off := base + 2 + n
+	length := size - off - int64(h.Size())
+	if length < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return aes.NewCTRReaderAt(io.NewSectionReader(fr, off, length), length, e)
+}
+
+// skipMetadata 跳过明文开头的元数据块, 见 utils.WriteMetadata
+func skipMetadata(ra sizedReaderAt) (sizedReaderAt, error) {
+	var n [4]byte
+	if _, err := ra.ReadAt(n[:], 0); err != nil {
+		return nil, err
+	}
+	off := 4 + int64(binary.BigEndian.Uint32(n[:]))
+	if off > ra.Size() {
+		return nil, utils.ErrNoMetadata
+	}
+	return io.NewSectionReader(ra, off, ra.Size()-off), nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"go-crypto/crypto-cli/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecryptRange(t *testing.T) {
+	dir := testFiles(t)
+	plain, err := os.ReadFile(filepath.Join(dir, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	passphrase := []string{"--passphrase", "--kdf", "scrypt", "--kdf-cost", "10"}
+	tests := []struct {
+		name string
+		enc  []string
+		dec  []string
+	}{
+		{name: "stream-passphrase", enc: passphrase, dec: []string{"--passphrase"}},
+		{name: "standard-ctr", enc: []string{"--public-key", filepath.Join("..", "public.key"), "--security", "aes-256-ctr"}, dec: []string{"--private-key", filepath.Join("..", "private.key")}},
+		{name: "preserve", enc: append([]string{"--preserve"}, passphrase...), dec: []string{"--passphrase"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := filepath.Join(t.TempDir(), "plain.enc")
+			args := append(append([]string{"encrypt"}, tt.enc...), "-f", filepath.Join(dir, "plain"), "-o", enc)
+			cmd := cliCommand(t, args...)
+			cmd.Env = append(cmd.Env, utils.PassphraseEnv+"=secret")
+			var output bytes.Buffer
+			cmd.Stderr = &output
+			if code := exitCode(t, cmd); code != 0 {
+				t.Fatalf("encrypt exit code = %d, output:\n%s", code, output.String())
+			}
+
+			out := filepath.Join(t.TempDir(), "part")
+			args = append(append([]string{"decrypt"}, tt.dec...), "--range", "1000:70000", "-f", enc, "-o", out)
+			cmd = cliCommand(t, args...)
+			cmd.Env = append(cmd.Env, utils.PassphraseEnv+"=secret")
+			output.Reset()
+			cmd.Stderr = &output
+			if code := exitCode(t, cmd); code != 0 {
+				t.Fatalf("decrypt --range exit code = %d, output:\n%s", code, output.String())
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plain[1000:70000]) {
+				t.Errorf("decrypt --range plaintext mismatch, %d bytes", len(got))
+			}
+		})
+	}
+}
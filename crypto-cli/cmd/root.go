@@ -4,23 +4,33 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"go-crypto/aes"
 	"go-crypto/crypto-cli/config"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
 	"go-crypto/version"
+	"io/fs"
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-const cipherBlockSep = "datacloak-cipher-block-separator"
-
 var conf config.Config
-var encryptor *aes.Encryptor
+
+var (
+	// cfgFile --config 指定的配置文件
+	cfgFile string
+	// profile --profile 指定的命名配置
+	profile string
+)
 
 var ciphers = map[string]struct{}{
 	//"aes-256-ecb": {},
@@ -28,6 +38,22 @@ var ciphers = map[string]struct{}{
 	"aes-256-ctr": {},
 	"aes-256-cfb": {},
 	"aes-256-ofb": {},
+	"aes-256-gcm": {},
+}
+
+const (
+	formatStandard = "standard"
+	formatStream   = "stream"
+)
+
+var formats = map[string]struct{}{
+	formatStandard: {},
+	formatStream:   {},
+}
+
+var kdfs = map[string]struct{}{
+	header.StanzaArgon2id: {},
+	header.StanzaScrypt:   {},
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -38,21 +64,36 @@ var rootCmd = &cobra.Command{
 	Long: fmt.Sprintf(`文件加解密工具.
 原理: 
 参考 HTTPS, 原始数据库使用对称加密算法 AES 进行加密, AES 所使用的密钥通过非对称加密算法 RSA 进行加密并存储于原始加密数据的头部;
-通过 HASH(MD5) 算法支持文件自校验.
+通过 HASH 算法(默认 SHA-256, 可通过 --hash 指定)支持文件自校验.
 
 使用示例:
 %s encrypt --public-key public.key -f your-src.file 使用指定公钥加密文件，加密后的文件直接覆盖原文件
 %s encrypt --public-key public.key -f your-src.file -o ciphered.file 使用指定公钥加密文件，加密后的文件不覆盖原文件
 %s encrypt -g -f your.file -o ciphered.file	自动生成密钥对并加密文件
 %s encrypt --public-key public.key --security aes-256-cbc -f your.file -o ciphered.file 使用指定公钥与加密算法
+%s encrypt --public-key public.key --hash blake2b -f your.file -o ciphered.file 使用指定的自校验哈希
+%s encrypt --public-key public.key --format stream -f your.file -o ciphered.file 使用分段认证加密格式
 %s decrypt --private-key private.key -f your-src.file 使用指定私钥解密指定文件，并覆盖原文件
-%s decrypt --private-key private.key --security aes-256-cbc -f your-src.file 使用指定私钥 算法 解密指定文件，并覆盖原文件
-%s decrypt --private-key private.key -f your-src.file -o unciphered.file 使用指定私钥解密指定文件，不覆盖原文件`,
-		version.App, version.App, version.App, version.App, version.App, version.App, version.App),
+%s decrypt --private-key private.key --security aes-256-cbc -f your-src.file 使用指定私钥 算法 解密旧版本生成的无文件头文件，并覆盖原文件
+%s decrypt --private-key private.key -f your-src.file -o unciphered.file 使用指定私钥解密指定文件，不覆盖原文件
+%s encrypt --passphrase -f your.file -o ciphered.file 使用口令加密文件, 口令经 argon2id/scrypt 派生密钥
+%s decrypt --passphrase -f ciphered.file -o your.file 使用口令解密文件
+%s keygen --bits 4096 --out-dir keys --name backup 生成 RSA 密钥对
+%s encrypt --recipient alice.key --recipient bob.key -f your.file 使用多个接收者的公钥加密文件, 任一私钥均可解密
+%s keygen --key-type x25519 --name backup 生成 X25519 密钥对, 加密/解密时根据密钥文件自动识别类型
+%s keygen --key-type mlkem768x25519 --name archive 生成抗量子的 ML-KEM-768 + X25519 混合密钥对
+%s sign --private-key signing.private.key -f your.file 生成分离签名文件 your.file.sig
+%s encrypt --public-key public.key --sign-key signing.private.key -f your.file 加密并对明文签名, 解密时验证签名
+pg_dump db | %s encrypt --public-key public.key -f - -o - | aws s3 cp - s3://bucket/db.enc 从标准输入读取, 写到标准输出
+%s encrypt --public-key public.key -r --exclude '*.tmp' -f data -o data.enc 加密目录下的所有文件, 输出到 data.enc 目录
+%s encrypt --public-key public.key -j 8 -o out *.csv 并发加密多个文件, 输出到 out 目录
+%s encrypt --public-key public.key --archive data --compress gzip -o backup.enc 将目录归档压缩后加密为单个文件, decrypt --extract 解压
+%s verify --private-key private.key backups/*.enc 校验加密文件完整性, 不输出明文
+%s encrypt --profile backups -f your.file 使用配置文件 profiles 下 backups 的公钥/算法等配置加密`,
+		version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App, version.App),
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		rand.Seed(time.Now().Unix())
 		ParseConfig(cmd, args)
-		Validate()
 	},
 	//Run: func(cmd *cobra.Command, args []string) {
 	//	InitEncryptor(cmd, args)
@@ -69,27 +110,121 @@ func Execute() {
 }
 
 func init() {
+	// 日志统一输出到标准错误, 避免输出到标准输出时混入加密/解密数据
+	log.SetOutput(os.Stderr)
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "conf", "", "conf file (default is $HOME/.go-crypto.yaml)")
+	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", `配置文件, 默认为 $XDG_CONFIG_HOME/crypto-cli/config.yaml(未设置时为 ~/.config/crypto-cli/config.yaml), 不存在时忽略
+配置项与命令行参数同名, 如 security: aes-256-gcm; profiles 下可定义多组命名配置, 由 profile 选择
+优先级: 命令行参数 > 环境变量 > profile > 配置文件顶层 > 默认值`)
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", `使用配置文件 profiles 下的命名配置, 如 --profile backups, 也可通过环境变量 CRYPTO_CLI_PROFILE 指定`)
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.PersistentFlags().BoolP("generate-key", "g", false, "指定是否自动生成 RSA 密钥对, 加密时可用")
+	rootCmd.PersistentFlags().BoolP("generate-key", "g", false, "指定是否自动生成密钥对, 加密时可用, 密钥类型由 key-type 指定")
+	rootCmd.PersistentFlags().String("key-type", utils.KeyTypeRSA, `generate-key 与 keygen 生成的密钥类型, 默认 rsa
+rsa: RSA 密钥对, 文件密钥使用 RSA 加密
+x25519: X25519 密钥对, 文件密钥使用 ECDH 协商并经 HKDF-SHA256 派生的密钥加密, 固定使用 stream 格式
+mlkem768x25519: ML-KEM-768 + X25519 混合密钥对, 抵御量子计算攻击, 适用于长期保存的文件, 固定使用 stream 格式
+ed25519: Ed25519 签名密钥对, 只能用于 sign 与 encrypt --sign-key
+加密/解密时根据公钥/私钥文件自动识别密钥类型`)
 	rootCmd.PersistentFlags().String("public-key", "", `公钥, 若不指定 generate-key, 则加密时必填`)
+	rootCmd.PersistentFlags().StringArray("recipient", nil, `接收者公钥文件, 可重复指定, 文件密钥为每个接收者分别加密, 任一接收者的私钥均可解密, 使用 stream 格式`)
+	rootCmd.PersistentFlags().String("recipients-file", "", `接收者公钥列表文件, 包含一个或多个 PEM 格式公钥, 如 cat alice.key bob.key > team.keys`)
 	rootCmd.PersistentFlags().String("private-key", "", `私钥, 解密时必填`)
-	rootCmd.PersistentFlags().StringP("security", "s", "aes-256-cbc", `加密方式, 默认 aes-256-cbc
+	rootCmd.PersistentFlags().StringP("security", "s", "aes-256-cbc", `加密方式, 默认 aes-256-cbc, 记录于文件头, 解密时自动识别, 仅解密旧版本生成的无文件头文件时需要指定
 支持如下方式
-aes-256-cbc aes-256-ctr aes-256-cfb aes-256-ofb`)
-	rootCmd.PersistentFlags().StringP("file", "f", "", `加密/解密的输入文件, 必填`)
-	rootCmd.PersistentFlags().StringP("out", "o", "", `加密/解密的输出文件, 不填则默认覆盖原文件`)
+aes-256-cbc aes-256-ctr aes-256-cfb aes-256-ofb aes-256-gcm(带认证, 可检测篡改)`)
+	rootCmd.PersistentFlags().String("format", formatStandard, `加密文件格式, 默认 standard, 解密时根据文件头自动识别
+standard: 整体加密, 通过 HASH 自校验
+stream: 分段认证加密(AES-256-GCM), 可流式解密, 可检测篡改/截断/重排, 忽略 security 参数`)
+	rootCmd.PersistentFlags().String("hash", utils.HashSHA256, `standard 格式的自校验哈希, 加密时可用, 默认 sha256, 记录于文件头, 解密时自动识别
+支持 sha256 sha512 blake2b sha3-256, 旧版本生成的无文件头的文件使用 md5`)
+	rootCmd.PersistentFlags().StringP("file", "f", "", `加密/解密的输入文件, 必填, - 表示标准输入; 也可通过位置参数指定一个或多个输入文件`)
+	rootCmd.PersistentFlags().StringP("out", "o", "", `加密/解密的输出文件, 不填则默认覆盖原文件, - 表示标准输出; 输入为标准输入时默认输出到标准输出
+多个输入文件或 recursive 时为输出目录`)
+	rootCmd.PersistentFlags().Bool("passphrase", false, `使用口令加密/解密, 无需 RSA 密钥对, 固定使用 stream 格式; keygen 时表示使用口令加密私钥
+口令依次从 passphrase-file, 环境变量 CRYPTO_CLI_PASSPHRASE, 终端交互输入(不回显) 获取`)
+	rootCmd.PersistentFlags().String("passphrase-file", "", `口令文件, 读取文件内容作为口令(忽略末尾换行)`)
+	rootCmd.PersistentFlags().String("key-passphrase-file", "", `私钥口令文件, 私钥经口令加密时使用
+未指定时依次从环境变量 CRYPTO_CLI_KEY_PASSPHRASE, 终端交互输入(不回显) 获取`)
+	rootCmd.PersistentFlags().String("kdf", header.StanzaArgon2id, `口令派生密钥算法, 加密时可用, 参数随机盐一同存储于文件头
+支持 argon2id scrypt`)
+	rootCmd.PersistentFlags().Int("kdf-cost", 0, `口令派生的计算强度, 加密时可用, 0 表示默认值
+argon2id: 迭代轮数, 默认 3, 范围 [1, 16]; scrypt: log2(N), 默认 15, 范围 [10, 22]`)
+	rootCmd.PersistentFlags().String("sign-key", "", `签名私钥(Ed25519 或 RSA), 加密时对明文签名并嵌入文件头, 使用 stream 格式
+私钥经口令加密时口令获取方式同 key-passphrase-file; 解密方须使用 signer 指定签名者公钥才能确认文件来源`)
+	rootCmd.PersistentFlags().String("signer", "", `签名者公钥, 解密时要求文件由该公钥对应的私钥签名, 否则解密失败
+只有 signer 能确认签名者身份: 未指定时只以文件头中嵌入的公钥检查签名, 任何能加密给你的人都可以嵌入自己的公钥和签名`)
+	rootCmd.PersistentFlags().String("signature", "", `分离签名文件, sign/verify 时可用, 默认为 输入文件.sig`)
+	rootCmd.PersistentFlags().Bool("json", false, `以 JSON 格式输出结果, inspect/verify 时可用`)
+	rootCmd.PersistentFlags().String("range", "", `只解密明文的部分数据, 格式 start:end (包含 start 不包含 end), 解密时可用, 必须同时指定 out
+仅支持 stream 格式与 standard 格式的 aes-256-ctr, 只解密范围所涉及的分组, standard 格式不校验 HASH`)
+	rootCmd.PersistentFlags().Bool("preserve", false, `加密时将原始文件名, 权限, 访问/修改时间与扩展属性随文件内容一同加密存储; 解密时恢复这些元数据(不恢复 setuid/setgid 位与文件能力)
+解密且 out 为已存在的目录时, 输出文件使用原始文件名; 输出到标准输出时不恢复; archive 模式下 tar 已记录权限与修改时间`)
+	rootCmd.PersistentFlags().BoolP("recursive", "r", false, `加密/解密 file 指定目录下的所有文件, 输出到 out 指定目录下的相同相对路径, 不填 out 则覆盖原文件
+单个文件失败不影响其他文件, 结束时输出成功与失败的文件数, 有失败时退出码非 0`)
+	rootCmd.PersistentFlags().IntP("jobs", "j", 1, `批量加密/解密(recursive 或多个输入文件)的并发数, 默认 1, 0 表示 CPU 核数`)
+	rootCmd.PersistentFlags().StringArray("include", nil, `recursive 时只处理匹配的文件, 可重复指定, 通配符与相对路径或文件名匹配, 如 *.log, logs/*.log`)
+	rootCmd.PersistentFlags().StringArray("exclude", nil, `recursive 时跳过匹配的文件或目录, 可重复指定, 通配符规则同 include`)
 	//rootCmd.PersistentFlags().Int32P("nonce", "n", 0, `随机数, 不大于2^32, 不传则系统随机生成`)
 
 	viper.BindPFlags(rootCmd.PersistentFlags())
 	//rootCmd.MarkPersistentFlagRequired("key-file")
-	rootCmd.MarkPersistentFlagRequired("file")
+}
+
+// envPrefix 环境变量前缀, 每个配置项对应 CRYPTO_CLI_ 加上大写的配置项名, - 替换为 _, 如 CRYPTO_CLI_PUBLIC_KEY
+const envPrefix = "CRYPTO_CLI"
+
+// initConfig 读取配置文件与环境变量, 在解析命令行参数之后, 命令执行之前调用
+func initConfig() {
+	// passphrase 对应的 CRYPTO_CLI_PASSPHRASE 用于传递口令本身, 不作为配置项
+	t := reflect.TypeOf(conf)
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" || key == "passphrase" {
+			continue
+		}
+		viper.BindEnv(key, envPrefix+"_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_")))
+	}
+
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+	} else {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return
+			}
+			dir = filepath.Join(home, ".config")
+		}
+		viper.SetConfigFile(filepath.Join(dir, "crypto-cli", "config.yaml"))
+	}
+	if err := viper.ReadInConfig(); err != nil {
+		// 默认配置文件不存在时忽略
+		if cfgFile != "" || !errors.Is(err, fs.ErrNotExist) {
+			log.Fatalf("[FATA] read config file:%s err:%s", viper.ConfigFileUsed(), err)
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv(envPrefix + "_PROFILE")
+	}
+	if profile == "" {
+		return
+	}
+	sub := viper.Sub("profiles." + profile)
+	if sub == nil {
+		log.Fatalf("[FATA] profile:%s not found in config file:%s", profile, viper.ConfigFileUsed())
+	}
+	if err := viper.MergeConfigMap(sub.AllSettings()); err != nil {
+		log.Fatalf("[FATA] load profile:%s err:%s", profile, err)
+	}
 }
 
 func ParseConfig(cmd *cobra.Command, args []string) {
@@ -100,11 +235,117 @@ func ParseConfig(cmd *cobra.Command, args []string) {
 	log.Printf("[INFO] conf:%+v", conf)
 }
 
-func Validate() {
+// Validate 校验加密/解密参数, 在 encrypt/decrypt 命令执行前调用.
+// 输入文件可由 --file 或位置参数指定, 多个输入或 --recursive 时为批量模式, 设置 inputs.
+func Validate(args []string) {
+	if conf.File == "" && len(args) == 1 {
+		conf.File, args = args[0], nil
+	}
+	if conf.File == "" && len(args) == 0 && conf.Archive == "" {
+		log.Fatalf("[FATA] required flag \"file\" not set")
+	}
 	if _, ok := ciphers[conf.Security]; !ok {
 		log.Fatalf("[FATA] invalid security cipher:%s", conf.Security)
 	}
-	if _, err := os.Stat(conf.File); err != nil {
-		log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+	if _, ok := formats[conf.Format]; !ok {
+		log.Fatalf("[FATA] invalid format:%s", conf.Format)
+	}
+	if _, ok := utils.KeyTypes[conf.KeyType]; !ok {
+		log.Fatalf("[FATA] invalid key type:%s", conf.KeyType)
+	}
+	if _, ok := utils.Hashes[conf.Hash]; !ok {
+		log.Fatalf("[FATA] invalid hash:%s", conf.Hash)
+	}
+	if conf.Jobs < 0 {
+		log.Fatalf("[FATA] invalid jobs:%d", conf.Jobs)
+	}
+	if _, ok := utils.Compressions[conf.Compress]; !ok {
+		log.Fatalf("[FATA] invalid compress:%s", conf.Compress)
+	}
+	if conf.Passphrase {
+		if _, ok := kdfs[conf.KDF]; !ok {
+			log.Fatalf("[FATA] invalid kdf:%s", conf.KDF)
+		}
+		// 口令模式固定使用 stream 格式
+		conf.Format = formatStream
+	}
+
+	if conf.Archive != "" || conf.Extract != "" {
+		validateArchive(args)
+		return
+	}
+
+	if len(args) == 0 && !conf.Recursive {
+		// 单文件模式
+		if conf.File == stdio {
+			return
+		}
+		info, err := os.Stat(conf.File)
+		if err != nil {
+			log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+		}
+		if info.IsDir() {
+			log.Fatalf("[FATA] file:%s is a directory, use --recursive", conf.File)
+		}
+		return
+	}
+
+	inputs = args
+	if conf.File != "" {
+		inputs = append([]string{conf.File}, args...)
+	}
+	if conf.Out == stdio || conf.Range != "" {
+		log.Fatalf("[FATA] multiple input files or --recursive can not be used with --out - or --range")
+	}
+	for _, in := range inputs {
+		if in == stdio {
+			log.Fatalf("[FATA] multiple input files or --recursive does not support stdin")
+		}
+		info, err := os.Stat(in)
+		if err != nil {
+			log.Fatalf("[FATA] open file:%s err:%s", in, err)
+		}
+		if info.IsDir() && !conf.Recursive {
+			log.Fatalf("[FATA] file:%s is a directory, use --recursive", in)
+		}
+	}
+	if out, err := os.Stat(conf.Out); err == nil && !out.IsDir() {
+		log.Fatalf("[FATA] out:%s must be a directory when processing multiple files", conf.Out)
+	}
+}
+
+// validateArchive 校验 encrypt --archive 与 decrypt --extract 的参数
+func validateArchive(args []string) {
+	if len(args) > 0 || conf.Recursive || conf.Range != "" {
+		log.Fatalf("[FATA] --archive/--extract can not be used with multiple input files, --recursive or --range")
+	}
+	if conf.Extract != "" {
+		if conf.Out != "" {
+			log.Fatalf("[FATA] --extract can not be used with --out")
+		}
+		if conf.File == stdio {
+			return
+		}
+		if _, err := os.Stat(conf.File); err != nil {
+			log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+		}
+		return
+	}
+
+	if conf.File != "" {
+		log.Fatalf("[FATA] --archive can not be used with --file")
+	}
+	if conf.Out == "" {
+		log.Fatalf("[FATA] --archive must be used with --out")
+	}
+	if conf.SignKey != "" || conf.Shred {
+		log.Fatalf("[FATA] --archive does not support --sign-key or --shred")
+	}
+	info, err := os.Stat(conf.Archive)
+	if err != nil {
+		log.Fatalf("[FATA] open dir:%s err:%s", conf.Archive, err)
+	}
+	if !info.IsDir() {
+		log.Fatalf("[FATA] --archive requires a directory, archive:%s", conf.Archive)
 	}
 }
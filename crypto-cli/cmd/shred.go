@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"go-crypto/crypto-cli/utils"
+	"log"
+	"os"
+	"sync"
+)
+
+// shredReported 已提示过的尽力而为原因, 每种只提示一次
+var shredReported sync.Map
+
+// openShred 打开 --shred 待粉碎的原文件, 未指定 --shred 时返回 nil.
+// 须在加密结果替换原文件之前打开, 替换后仍可通过该文件覆盖原文件的数据块.
+func openShred(f string) (*os.File, error) {
+	if !conf.Shred {
+		return nil, nil
+	}
+	return os.OpenFile(f, os.O_WRONLY, 0)
+}
+
+// shredFile 在加密成功后粉碎原文件: 以随机数据覆盖 --shred-passes 遍并同步, 截断后关闭;
+// replaced 为 false 表示原文件未被加密结果替换(指定了 --out), 此时删除原文件.
+func shredFile(sf *os.File, replaced bool) error {
+	defer sf.Close()
+	name := sf.Name()
+	if reason := utils.ShredBestEffort(name); reason != "" {
+		if _, ok := shredReported.LoadOrStore(reason, true); !ok {
+			log.Printf("[INFO] shred is best-effort only, %s, original data may remain on disk", reason)
+		}
+	}
+	if err := utils.Shred(sf, conf.ShredPasses); err != nil {
+		return err
+	}
+	if !replaced {
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	log.Printf("[INFO] shredded %s, %d passes", name, conf.ShredPasses)
+	return nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// signCmd represents the sign command
+var signCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "对文件签名",
+	Long: `使用 Ed25519 或 RSA 私钥对文件生成分离签名文件, Ed25519 使用 Ed25519ph(SHA-512), RSA 使用 RSA-PSS(SHA-256).
+示例:
+
+crypto-cli keygen --key-type ed25519 --name signing
+crypto-cli sign --private-key signing.private.key -f your.file   生成 your.file.sig
+crypto-cli sign --private-key signing.private.key -f your.file --signature release.sig`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		validateSignArgs()
+		if conf.PrivateKey == "" {
+			log.Fatalf("[FATA] required flag \"private-key\" not set")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		priKey, err := utils.ReadPrivateKey(conf.PrivateKey, conf.KeyPassphraseFile)
+		if err != nil {
+			log.Fatalf("[FATA] Could not read private key file:%s", err)
+		}
+		signer, err := utils.NewSigner(priKey)
+		if err != nil {
+			log.Fatalf("[FATA] invalid sign key:%s", err)
+		}
+
+		fr, err := os.Open(conf.File)
+		if err != nil {
+			log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+		}
+		defer fr.Close()
+		h := signer.Hash()
+		if _, err := io.Copy(h, fr); err != nil {
+			log.Fatalf("[FATA] read file:%s err:%s", conf.File, err)
+		}
+		sig, err := signer.Sign(h.Sum(nil))
+		if err != nil {
+			log.Fatalf("[FATA] sign file:%s err:%s", conf.File, err)
+		}
+		if err := os.WriteFile(conf.Signature, utils.EncodeSignature(sig), 0644); err != nil {
+			log.Fatalf("[FATA] write signature file:%s err:%s", conf.Signature, err)
+		}
+		fmt.Printf("签名: %s\n指纹: %s\n", conf.Signature, sig.Fingerprint)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signCmd)
+}
+
+// validateSignArgs 校验 sign/verify 的输入文件, 未指定签名文件时默认为 输入文件.sig
+func validateSignArgs() {
+	if conf.File == "" {
+		log.Fatalf("[FATA] required flag \"file\" not set")
+	}
+	if _, err := os.Stat(conf.File); err != nil {
+		log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+	}
+	if conf.Signature == "" {
+		conf.Signature = conf.File + ".sig"
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go-crypto/aes"
+	"go-crypto/crypto-cli/header"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+)
+
+const (
+	// fileKeySize 分段格式下随机生成的文件密钥长度, AES-256
+	fileKeySize = 32
+	// streamCipher 分段格式固定使用的加密算法
+	streamCipher = "aes-256-gcm"
+)
+
+// signInput 计算 r 中明文的摘要并签名, 签名附带签名者公钥, 之后将 r 恢复到开头.
+// r 须可 Seek, 不支持标准输入.
+func signInput(r io.Reader, signer *utils.Signer) (*header.Signature, error) {
+	rs, ok := r.(io.Seeker)
+	if ok {
+		_, err := rs.Seek(0, io.SeekCurrent)
+		ok = err == nil
+	}
+	if !ok {
+		return nil, errors.New("signing requires a seekable input, stdin is not supported")
+	}
+	digest := signer.Hash()
+	if _, err := io.Copy(digest, r); err != nil {
+		return nil, err
+	}
+	sig, err := signer.Sign(digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	sig.PublicKey = signer.PublicKey()
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// newFileKey 随机生成分段格式的文件密钥
+func newFileKey() ([]byte, error) {
+	key := make([]byte, fileKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// encStreamFile 使用分段认证加密格式加密 r 写入 w, 文件密钥 key 由各接收者包装后存储于文件头.
+// h 为调用方预先设置了签名/归档/压缩等字段的文件头, 其余字段由本函数填写.
+func encStreamFile(r io.Reader, w io.Writer, h *header.Header, recipients []utils.Recipient, key []byte) error {
+	h.Format = header.FormatStream
+	h.Cipher = streamCipher
+	for _, recipient := range recipients {
+		stanza, err := recipient.Wrap(key)
+		if err != nil {
+			return err
+		}
+		h.Recipients = append(h.Recipients, *stanza)
+	}
+	h.Version = header.Version
+	if err := header.Write(w, h, key); err != nil {
+		return err
+	}
+
+	sw, err := aes.NewStreamWriter(w, key, aes.DefaultChunkSize, h.MAC())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// decStreamFile 解密分段认证加密格式的数据, key 为已从文件头解出的文件密钥, ad 为文件头的 MAC(旧版本文件头为 nil)
+func decStreamFile(r io.Reader, w io.Writer, key, ad []byte) error {
+	sr, err := aes.NewStreamReader(r, key, ad)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, sr)
+	return err
+}
+
+// streamKey 从 stream 格式的文件头中解出文件密钥
+func streamKey(h *header.Header, id utils.Identity) ([]byte, error) {
+	if h.Format != header.FormatStream {
+		return nil, fmt.Errorf("unsupported format:%s", h.Format)
+	}
+	return id.Unwrap(h.Recipients)
+}
+
+// streamReaderAt 返回分段格式文件的随机访问解密 Reader, off 为文件头之后的偏移
+func streamReaderAt(fr *os.File, off, size int64, ff *fileFormat) (sizedReaderAt, error) {
+	if ff.h == nil {
+		return nil, header.ErrNoHeader
+	}
+	if ff.h.Signature != nil {
+		log.Printf("[INFO] file signed by %s, signature is not verified when decrypting a range", ff.h.Signature.Fingerprint)
+	}
+	return aes.NewStreamReaderAt(io.NewSectionReader(fr, off, size-off), size-off, ff.key, ff.h.MAC())
+}
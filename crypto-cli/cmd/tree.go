@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// inputs 批量模式的输入文件/目录, 由 Validate 根据 --file 与位置参数设置, 为空时为单文件模式
+var inputs []string
+
+// fileJob 批量模式下单个文件的加密/解密任务
+type fileJob struct {
+	src string
+	// dst 输出文件, 未指定 --out 时与 src 相同, 即覆盖原文件
+	dst string
+}
+
+// batchJobs 生成 inputs 中每个文件的任务, 目录递归展开.
+// 指定 --out 时输出到该目录下: 只有一个输入目录时保留其下的相对路径, 多个输入时以输入文件/目录名区分.
+func batchJobs() ([]fileJob, error) {
+	var jobs []fileJob
+	for _, in := range inputs {
+		var out string
+		if conf.Out != "" {
+			out = filepath.Join(conf.Out, filepath.Base(in))
+		}
+		info, err := os.Stat(in)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if out == "" {
+				out = in
+			}
+			jobs = append(jobs, fileJob{src: in, dst: out})
+			continue
+		}
+		if len(inputs) == 1 {
+			out = conf.Out
+		}
+		dirJobs, err := treeJobs(in, out)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, dirJobs...)
+	}
+
+	dsts := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		dst := filepath.Clean(job.dst)
+		if src, ok := dsts[dst]; ok {
+			return nil, fmt.Errorf("%s and %s have the same output:%s", src, job.src, job.dst)
+		}
+		dsts[dst] = job.src
+	}
+	return jobs, nil
+}
+
+// treeJobs 遍历目录 root, 生成每个普通文件的任务, 输出到 out 目录下的相同相对路径, out 为空时覆盖原文件.
+// 输出目录位于输入目录内时跳过其中的文件.
+func treeJobs(root, out string) ([]fileJob, error) {
+	files, err := utils.WalkFiles(root, conf.Include, conf.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	var skip string
+	if out != "" {
+		if rel, err := filepath.Rel(root, out); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			skip = filepath.ToSlash(rel) + "/"
+		}
+	}
+
+	jobs := make([]fileJob, 0, len(files))
+	for _, rel := range files {
+		if skip != "" && strings.HasPrefix(rel, skip) {
+			continue
+		}
+		job := fileJob{src: filepath.Join(root, filepath.FromSlash(rel))}
+		job.dst = job.src
+		if out != "" {
+			job.dst = filepath.Join(out, filepath.FromSlash(rel))
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// runBatch 使用 fn 加密/解密 inputs 中的所有文件, 单个文件失败不影响其他文件, 返回失败的文件数
+func runBatch(suffix string, fn func(r io.Reader, w io.Writer) error) int {
+	jobs, err := batchJobs()
+	if err != nil {
+		log.Fatalf("[FATA] %s", err)
+	}
+
+	// Ctrl-C 时停止分发新任务, 正在处理的文件读取输入时返回错误, 由 runJob 删除临时文件
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// 第一次信号后恢复默认处理, 再次 Ctrl-C 时强制退出卡住的任务
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return runJobs(ctx, jobs, suffix, fn)
+}
+
+// runJobs 使用 --jobs 个 worker 并发执行 jobs, 按 jobs 的顺序打印进度, 结束时打印汇总信息, 返回失败的文件数
+func runJobs(ctx context.Context, jobs []fileJob, suffix string, fn func(r io.Reader, w io.Writer) error) int {
+	workers := conf.Jobs
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]chan error, len(jobs))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range jobs {
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				for ; i < len(jobs); i++ {
+					results[i] <- ctx.Err()
+				}
+				return
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range queue {
+				results[i] <- runJob(ctx, jobs[i], suffix, fn)
+			}
+		}()
+	}
+
+	var failed []string
+	canceled := 0
+	for i, job := range jobs {
+		err := <-results[i]
+		switch {
+		case err == nil:
+			log.Printf("[INFO] [%d/%d] %s -> %s", i+1, len(jobs), job.src, job.dst)
+		case ctx.Err() != nil && err == ctx.Err():
+			canceled++
+		default:
+			log.Printf("[ERROR] [%d/%d] file:%s err:%s", i+1, len(jobs), job.src, err)
+			failed = append(failed, job.src)
+		}
+	}
+
+	log.Printf("[INFO] %d files, %d succeeded, %d failed, %d canceled", len(jobs), len(jobs)-len(failed)-canceled, len(failed), canceled)
+	for _, f := range failed {
+		log.Printf("[INFO] failed: %s", f)
+	}
+	return len(failed) + canceled
+}
+
+// runJob 使用 fn 处理单个文件, 先写入 dst 所在目录的临时文件, 成功后原子地替换 dst, 失败或取消时删除临时文件
+func runJob(ctx context.Context, job fileJob, suffix string, fn func(r io.Reader, w io.Writer) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	in, err := os.Open(job.src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(job.dst), 0755); err != nil {
+		return err
+	}
+	sf, err := openShred(job.src)
+	if err != nil {
+		return err
+	}
+	if sf != nil {
+		defer sf.Close()
+	}
+	out, err := createOutput(job.dst, suffix)
+	if err != nil {
+		return err
+	}
+	if err := fn(&ctxReader{ctx: ctx, f: in}, out); err != nil {
+		out.remove()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	// 覆盖原文件前须关闭输入
+	in.Close()
+	if err := out.finish(); err != nil {
+		return err
+	}
+	if sf != nil {
+		if err := shredFile(sf, filepath.Clean(job.src) == filepath.Clean(job.dst)); err != nil {
+			return fmt.Errorf("encrypted to %s, but shred failed: %w", job.dst, err)
+		}
+	}
+	return nil
+}
+
+// ctxReader 在 ctx 取消后读取返回错误, 用于中断正在处理的文件.
+// 不嵌入 *os.File, 避免 io.Copy 通过 WriterTo 绕过 Read; 签名需要 Seek 重新读取输入, --preserve 需要文件名.
+type ctxReader struct {
+	ctx context.Context
+	f   *os.File
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.f.Read(p)
+}
+
+// Name 返回输入文件名, 用于读取原始文件元数据
+func (r *ctxReader) Name() string {
+	return r.f.Name()
+}
+
+func (r *ctxReader) Seek(offset int64, whence int) (int64, error) {
+	return r.f.Seek(offset, whence)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// batchFiles 在临时目录中创建 n 个输入文件, 返回输出到 out 目录的任务
+func batchFiles(t *testing.T, n int) ([]fileJob, string) {
+	t.Helper()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	var jobs []fileJob
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("f%d", i)
+		src := filepath.Join(dir, name)
+		if err := os.WriteFile(src, bytes.Repeat([]byte(name), 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, fileJob{src: src, dst: filepath.Join(out, name)})
+	}
+	return jobs, out
+}
+
+// setJobs 设置 --jobs 并捕获日志输出, 测试结束时恢复
+func setJobs(t *testing.T, jobs int) *bytes.Buffer {
+	t.Helper()
+	old := conf
+	conf.Jobs = jobs
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() {
+		conf = old
+		log.SetOutput(os.Stderr)
+	})
+	return &logs
+}
+
+func TestRunJobs(t *testing.T) {
+	logs := setJobs(t, 3)
+	jobs, out := batchFiles(t, 6)
+
+	var running, peak int32
+	fn := func(r io.Reader, w io.Writer) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		name := r.(interface{ Name() string }).Name()
+		// 前面的文件处理得更慢, 完成顺序与输入顺序不同
+		if strings.HasSuffix(name, "f0") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		if strings.HasSuffix(name, "f2") {
+			return errors.New("job failed")
+		}
+		return nil
+	}
+	if failed := runJobs(context.Background(), jobs, ".enc", fn); failed != 1 {
+		t.Errorf("runJobs() failed = %d, want 1", failed)
+	}
+	if peak > 3 {
+		t.Errorf("runJobs() ran %d jobs at the same time, want <= 3", peak)
+	}
+
+	// 失败的文件没有输出与临时文件, 其他文件正常输出
+	for i, job := range jobs {
+		_, err := os.Stat(job.dst)
+		if (i == 2) != errors.Is(err, os.ErrNotExist) {
+			t.Errorf("output %s stat error = %v", job.dst, err)
+		}
+	}
+	if names := dirNames(t, out); len(names) != len(jobs)-1 {
+		t.Errorf("output dir = %v, want %d files", names, len(jobs)-1)
+	}
+
+	// 进度按输入顺序打印
+	last := -1
+	for i := range jobs {
+		idx := strings.Index(logs.String(), fmt.Sprintf("[%d/%d]", i+1, len(jobs)))
+		if idx < last {
+			t.Errorf("progress of job %d is printed out of order:\n%s", i+1, logs.String())
+		}
+		last = idx
+	}
+}
+
+func TestRunJobsCanceled(t *testing.T) {
+	setJobs(t, 2)
+
+	t.Run("before-start", func(t *testing.T) {
+		jobs, out := batchFiles(t, 4)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fn := func(r io.Reader, w io.Writer) error {
+			_, err := io.Copy(w, r)
+			return err
+		}
+		if failed := runJobs(ctx, jobs, ".enc", fn); failed != len(jobs) {
+			t.Errorf("runJobs() failed = %d, want %d", failed, len(jobs))
+		}
+		if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("output dir stat error = %v, want not exist", err)
+		}
+	})
+
+	t.Run("while-running", func(t *testing.T) {
+		jobs, out := batchFiles(t, 4)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		fn := func(r io.Reader, w io.Writer) error {
+			// 写出部分数据后取消, 之后读取输入返回错误
+			buf := make([]byte, 100)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return err
+			}
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			cancel()
+			_, err := io.Copy(w, r)
+			return err
+		}
+		if failed := runJobs(ctx, jobs, ".enc", fn); failed != len(jobs) {
+			t.Errorf("runJobs() failed = %d, want %d", failed, len(jobs))
+		}
+		// 部分写入的临时文件已删除
+		if names := dirNames(t, out); len(names) != 0 {
+			t.Errorf("output dir = %v, want empty", names)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-crypto/crypto-cli/utils"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [file...]",
+	Short: "验证文件签名或加密文件的完整性",
+	Long: `指定 --public-key 时使用签名者公钥验证 sign 生成的分离签名文件, 签名无效时以非零状态码退出.
+指定 --private-key 或 --passphrase 时完整解密加密文件并丢弃明文, 校验自校验哈希/认证标签以及文件头中的签名, 不在磁盘上生成明文;
+文件头中的签名只有同时指定 --signer 时才能确认签名者身份, 否则只表示签名与文件中嵌入的公钥一致;
+可同时校验多个文件(位置参数, --recursive 目录, --jobs 并发), 每个文件输出一行结果, --json 时输出 JSON, 有文件校验失败时退出码为 1.
+示例:
+
+crypto-cli verify --public-key signing.public.key -f your.file   验证 your.file.sig
+crypto-cli verify --public-key signing.public.key -f your.file --signature release.sig
+crypto-cli verify --private-key private.key -f backup.enc   校验加密文件完整性
+crypto-cli verify --private-key private.key -j 4 --json backups/*.enc   并发校验多个加密文件, 输出 JSON
+crypto-cli verify --private-key private.key -r --include '*.enc' backups   校验目录下的所有加密文件`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if conf.PublicKey == "" {
+			if conf.PrivateKey == "" && !conf.Passphrase {
+				log.Fatalf("[FATA] --public-key, --private-key or --passphrase must Specify one")
+			}
+			return
+		}
+		if conf.PrivateKey != "" || conf.Passphrase {
+			log.Fatalf("[FATA] --public-key verifies a signature, it cannot be used with --private-key or --passphrase")
+		}
+		validateSignArgs()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if conf.PublicKey == "" {
+			if failed := verifyFiles(cmd, args); failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+
+		pubKey, err := os.ReadFile(conf.PublicKey)
+		if err != nil {
+			log.Fatalf("[FATA] Could not read public key file:%s", err)
+		}
+		data, err := os.ReadFile(conf.Signature)
+		if err != nil {
+			log.Fatalf("[FATA] Could not read signature file:%s", err)
+		}
+		sig, err := utils.DecodeSignature(data)
+		if err != nil {
+			log.Fatalf("[FATA] signature file:%s err:%s", conf.Signature, err)
+		}
+
+		fr, err := os.Open(conf.File)
+		if err != nil {
+			log.Fatalf("[FATA] open file:%s err:%s", conf.File, err)
+		}
+		defer fr.Close()
+		digest, err := utils.Digest(sig.Algorithm, fr)
+		if err != nil {
+			log.Fatalf("[FATA] read file:%s err:%s", conf.File, err)
+		}
+		if err := utils.VerifySignature(pubKey, sig, digest); err != nil {
+			log.Fatalf("[FATA] file:%s %s", conf.File, err)
+		}
+		fmt.Printf("签名有效\n签名者: %s\n算法: %s\n", sig.Fingerprint, sig.Algorithm)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// verifyResult 一个加密文件的完整性校验结果
+type verifyResult struct {
+	File   string `json:"file"`
+	OK     bool   `json:"ok"`
+	Format string `json:"format,omitempty"`
+	// Signer 文件头中签名的签名者公钥指纹, 签名已验证
+	Signer string `json:"signer,omitempty"`
+	// SignerAuthenticated 签名者公钥由 --signer 指定, 为 false 时签名者身份未经确认
+	SignerAuthenticated bool   `json:"signer_authenticated,omitempty"`
+	Error               string `json:"error,omitempty"`
+}
+
+// verifyFiles 校验 --file 与 args 指定的加密文件的完整性, 目录在 --recursive 时展开.
+// 按输入顺序每个文件输出一行结果, --json 时输出 JSON 数组, 返回校验失败的文件数.
+func verifyFiles(cmd *cobra.Command, args []string) int {
+	files, err := verifyInputs(args)
+	if err != nil {
+		log.Fatalf("[FATA] %s", err)
+	}
+	opts := newDecOptions(cmd)
+	opts.quiet = true
+
+	workers := conf.Jobs
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]chan *verifyResult, len(files))
+	for i := range results {
+		results[i] = make(chan *verifyResult, 1)
+	}
+	queue := make(chan int)
+	go func() {
+		defer close(queue)
+		for i := range files {
+			queue <- i
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range queue {
+				results[i] <- opts.verifyFile(files[i])
+			}
+		}()
+	}
+
+	all := make([]*verifyResult, 0, len(files))
+	failed := 0
+	for i := range files {
+		res := <-results[i]
+		if !res.OK {
+			failed++
+		}
+		if conf.JSON {
+			all = append(all, res)
+			continue
+		}
+		if res.OK {
+			fmt.Printf("%s: OK, %s\n", res.File, res.signature())
+		} else {
+			fmt.Printf("%s: FAILED %s\n", res.File, res.Error)
+		}
+	}
+	if conf.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(all); err != nil {
+			log.Fatalf("[FATA] encode json err:%s", err)
+		}
+	}
+	log.Printf("[INFO] %d files, %d ok, %d failed", len(files), len(files)-failed, failed)
+	return failed
+}
+
+// verifyInputs 返回待校验的文件, "-" 表示标准输入
+func verifyInputs(args []string) ([]string, error) {
+	if conf.File != "" {
+		args = append([]string{conf.File}, args...)
+	}
+	if len(args) == 0 {
+		return nil, errors.New("required flag \"file\" not set")
+	}
+	var files []string
+	for _, in := range args {
+		if in == stdio {
+			if len(args) > 1 {
+				return nil, errors.New("stdin cannot be used with other inputs")
+			}
+			files = append(files, in)
+			continue
+		}
+		info, err := os.Stat(in)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, in)
+			continue
+		}
+		if !conf.Recursive {
+			return nil, fmt.Errorf("%s is a directory, use --recursive", in)
+		}
+		rels, err := utils.WalkFiles(in, conf.Include, conf.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range rels {
+			files = append(files, filepath.Join(in, filepath.FromSlash(rel)))
+		}
+	}
+	return files, nil
+}
+
+// verifyFile 完整解密文件 f 并丢弃明文, 校验自校验哈希/认证标签与签名
+func (o *decOptions) verifyFile(f string) *verifyResult {
+	res := &verifyResult{File: f}
+	err := func() error {
+		in, err := openInput(f)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		br := bufio.NewReader(in)
+		ff, err := o.parse(br)
+		if err != nil {
+			return err
+		}
+		res.Format = ff.format
+		if err := o.decryptPayload(br, io.Discard, ff); err != nil {
+			return err
+		}
+		if ff.h != nil && ff.h.Signature != nil {
+			res.Signer = ff.h.Signature.Fingerprint
+			res.SignerAuthenticated = o.signerKey != nil
+		}
+		return nil
+	}()
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.OK = err == nil
+	return res
+}
+
+// signature 返回签名状态的说明
+func (r *verifyResult) signature() string {
+	switch {
+	case r.Signer == "":
+		return "not signed"
+	case r.SignerAuthenticated:
+		return "signed by " + r.Signer
+	default:
+		return "signed by " + r.Signer + " (not authenticated, use --signer)"
+	}
+}
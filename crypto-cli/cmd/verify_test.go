@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-crypto/crypto-cli/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFiles(t *testing.T) {
+	dir := testFiles(t)
+	priKey, pubKey := utils.KeyPaths(dir, "")
+	encDir := filepath.Join(dir, "enc")
+	if err := os.Mkdir(encDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if code, output := runCLI(t, "encrypt", "--public-key", pubKey, "-f", filepath.Join(dir, "plain"), "-o", filepath.Join(encDir, name+".enc")); code != 0 {
+			t.Fatalf("encrypt exit code = %d, output:\n%s", code, output)
+		}
+	}
+	a, b, c := filepath.Join(encDir, "a.enc"), filepath.Join(encDir, "b.enc"), filepath.Join(encDir, "c.enc")
+
+	t.Run("ok", func(t *testing.T) {
+		code, output := runCLI(t, "verify", "--private-key", priKey, "-j", "2", a, b, c)
+		if code != 0 {
+			t.Fatalf("verify exit code = %d, output:\n%s", code, output)
+		}
+		for _, f := range []string{a, b, c} {
+			if !strings.Contains(output, f+": OK, not signed\n") {
+				t.Errorf("verify output does not contain OK line of %s:\n%s", f, output)
+			}
+		}
+		// 签名状态已在结果行中给出, 不再逐个文件打印日志
+		if strings.Contains(output, "file is not signed") {
+			t.Errorf("verify logs signature status of every file:\n%s", output)
+		}
+	})
+
+	t.Run("recursive-json", func(t *testing.T) {
+		// 截断一个文件, 其他文件不受影响
+		data, err := os.ReadFile(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(b, data[:len(data)-10], 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.WriteFile(b, data, 0644)
+
+		var stdout, stderr bytes.Buffer
+		cmd := cliCommand(t, "verify", "--private-key", priKey, "-r", "--json", encDir)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if code := exitCode(t, cmd); code != 1 {
+			t.Fatalf("verify exit code = %d, want 1, stderr:\n%s", code, stderr.String())
+		}
+		var results []verifyResult
+		if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+			t.Fatalf("verify --json is not valid json: %v\n%s", err, stdout.String())
+		}
+		if len(results) != 3 {
+			t.Fatalf("verify --json results = %+v, want 3", results)
+		}
+		for _, res := range results {
+			if failed := res.File == b; res.OK == failed || failed != (res.Error != "") {
+				t.Errorf("verify --json result = %+v", res)
+			}
+		}
+	})
+}
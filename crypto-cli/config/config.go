@@ -1,10 +1,59 @@
 package config
 
 type Config struct {
-	PublicKey   string `mapstructure:"public-key"`
-	PrivateKey  string `mapstructure:"private-key"`
-	GenerateKey bool   `mapstructure:"generate-key"`
-	Security    string `mapstructure:"security"`
-	File        string `mapstructure:"file"`
-	Out         string `mapstructure:"out"`
+	PublicKey string `mapstructure:"public-key"`
+	// Recipients 多个接收者的公钥文件, 可与 PublicKey 同时使用
+	Recipients     []string `mapstructure:"recipient"`
+	RecipientsFile string   `mapstructure:"recipients-file"`
+	PrivateKey     string   `mapstructure:"private-key"`
+	GenerateKey    bool     `mapstructure:"generate-key"`
+	KeyType        string   `mapstructure:"key-type"`
+	Security       string   `mapstructure:"security"`
+	Format         string   `mapstructure:"format"`
+	Hash           string   `mapstructure:"hash"`
+	File           string   `mapstructure:"file"`
+	Out            string   `mapstructure:"out"`
+	Range          string   `mapstructure:"range"`
+
+	// Recursive 加密/解密目录下的所有文件, Include/Exclude 为相对路径或文件名的通配符
+	Recursive bool     `mapstructure:"recursive"`
+	Include   []string `mapstructure:"include"`
+	Exclude   []string `mapstructure:"exclude"`
+	// Jobs 批量加密/解密的并发数, 0 表示 CPU 核数
+	Jobs int `mapstructure:"jobs"`
+
+	// Archive 加密时归档的目录, Extract 解密时解压的目录
+	Archive  string `mapstructure:"archive"`
+	Extract  string `mapstructure:"extract"`
+	Compress string `mapstructure:"compress"`
+	// Preserve 加密时存储, 解密时恢复原始文件的元数据
+	Preserve bool `mapstructure:"preserve"`
+	// Verify 加密完成后校验加密结果, 通过后才替换原文件
+	Verify bool `mapstructure:"verify"`
+	// Shred 加密成功后粉碎原文件, ShredPasses 为随机数据覆盖的遍数
+	Shred       bool `mapstructure:"shred"`
+	ShredPasses int  `mapstructure:"shred-passes"`
+
+	Passphrase     bool   `mapstructure:"passphrase"`
+	PassphraseFile string `mapstructure:"passphrase-file"`
+	// KeyPassphraseFile 口令加密的私钥所使用的口令文件
+	KeyPassphraseFile string `mapstructure:"key-passphrase-file"`
+	KDF               string `mapstructure:"kdf"`
+	KDFCost           int    `mapstructure:"kdf-cost"`
+
+	// SignKey 加密时对明文签名所用的私钥
+	SignKey string `mapstructure:"sign-key"`
+	// Signer 解密时要求的签名者公钥
+	Signer string `mapstructure:"signer"`
+	// Signature sign/verify 的分离签名文件
+	Signature string `mapstructure:"signature"`
+
+	// inspect
+	JSON bool `mapstructure:"json"`
+
+	// keygen
+	Bits   int    `mapstructure:"bits"`
+	OutDir string `mapstructure:"out-dir"`
+	Name   string `mapstructure:"name"`
+	Force  bool   `mapstructure:"force"`
 }
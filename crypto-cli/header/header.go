@@ -0,0 +1,263 @@
+package header
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 文件头格式: 魔数(8) | 头部长度(4, 大端) | 头部内容(JSON) | MAC(32, 版本 7 起)
+// MAC = HMAC-SHA256(HKDF-SHA256(文件密钥), 魔数 | 头部长度 | 头部内容), 解密时解出文件密钥后校验,
+// 篡改任何字段(如删除压缩算法, 修改元数据标记)都会导致解密失败.
+// 头部之后紧跟加密数据, 其格式由 Header.Format 决定.
+//
+// 头部内容自描述解密所需的全部参数: 版本, 格式, 加密算法与模式, 自校验哈希, 各接收者的文件密钥包装方式及口令派生参数,
+// 解密时无需再指定这些参数. 新增算法只需新增标识, 读取到不支持的标识时返回错误, 不影响已有文件.
+
+const (
+	// Magic 文件头魔数
+	Magic = "GOCRYPTO"
+	// Version 当前文件头版本
+	// 1: 初始版本
+	// 2: 新增 mlkem768x25519 接收者类型
+	// 3: 新增明文签名
+	// 4: 新增 standard 格式文件头, 记录自校验哈希
+	// 5: 新增目录归档与压缩
+	// 6: 新增加密的原始文件元数据
+	// 7: 新增头部 MAC, 认证文件头的全部字段; 新文件均为版本 7
+	Version = 7
+	// MACVersion 文件头带有 MAC 的最低版本
+	MACVersion = 7
+	// MACSize 头部 MAC(HMAC-SHA256) 的长度
+	MACSize = sha256.Size
+
+	// macInfo 由文件密钥派生 MAC 密钥时 HKDF 的 info
+	macInfo = "crypto-cli header mac"
+
+	// maxSize 头部内容的最大长度
+	maxSize = 1 << 20
+)
+
+// 加密数据格式
+const (
+	// FormatStream 分段认证加密格式, 见 aes.NewStreamWriter
+	FormatStream = "stream"
+	// FormatStandard EncryptionFile 格式, 文件密钥由 RSA 加密后存储于加密数据中, 通过 Header.Hash 自校验.
+	// 旧版本程序生成的 standard 格式文件没有文件头, 固定使用 MD5
+	FormatStandard = "standard"
+)
+
+// 文件密钥的包装方式
+const (
+	// StanzaRSA 使用 RSA 公钥加密文件密钥
+	StanzaRSA = "rsa"
+	// StanzaScrypt 使用口令经 scrypt 派生的密钥加密文件密钥
+	StanzaScrypt = "scrypt"
+	// StanzaArgon2id 使用口令经 Argon2id 派生的密钥加密文件密钥
+	StanzaArgon2id = "argon2id"
+	// StanzaX25519 使用 X25519 ECDH 协商并经 HKDF 派生的密钥加密文件密钥
+	StanzaX25519 = "x25519"
+	// StanzaMLKEM768X25519 使用 ML-KEM-768 与 X25519 混合密钥封装派生的密钥加密文件密钥, 抵御量子计算攻击
+	StanzaMLKEM768X25519 = "mlkem768x25519"
+)
+
+// 签名算法
+const (
+	// SignatureEd25519 Ed25519ph, 对明文的 SHA-512 摘要签名
+	SignatureEd25519 = "ed25519ph"
+	// SignatureRSAPSS RSA-PSS, 对明文的 SHA-256 摘要签名
+	SignatureRSAPSS = "rsa-pss-sha256"
+)
+
+var (
+	// ErrNoHeader 文件不以魔数开头, 不是带文件头的加密文件
+	ErrNoHeader = errors.New("header: magic not found")
+	// ErrUnsupportedVersion 文件头版本高于当前程序支持的版本
+	ErrUnsupportedVersion = errors.New("header: unsupported version")
+	// ErrBadMAC 文件头 MAC 校验失败, 文件头被修改或文件密钥不匹配
+	ErrBadMAC = errors.New("header: MAC mismatch, header has been modified")
+	// ErrNoMAC 版本 7 之前的文件头没有 MAC
+	ErrNoMAC = errors.New("header: not authenticated")
+)
+
+// Header 加密文件头, 描述加密数据的格式以及文件密钥的包装方式
+type Header struct {
+	Version    int      `json:"version"`
+	Format     string   `json:"format"`
+	Recipients []Stanza `json:"recipients,omitempty"`
+	// Cipher 加密数据所用的对称加密算法标识, 格式为 算法-密钥长度-模式, 如 aes-256-cbc, 与 --security 一致.
+	// 版本 1-3 的 stream 格式未记录, 固定为 aes-256-gcm
+	Cipher string `json:"cipher,omitempty"`
+	// Hash standard 格式的自校验哈希
+	Hash string `json:"hash,omitempty"`
+	// Signature 加密时对明文的签名, 可选
+	Signature *Signature `json:"signature,omitempty"`
+	// Archive 明文为目录归档(tar)时的归档格式, 可选
+	Archive string `json:"archive,omitempty"`
+	// Compression 明文加密前的压缩算法, 如 gzip, zstd, 可选
+	Compression string `json:"compression,omitempty"`
+	// Metadata 明文开头是否带有原始文件元数据块(文件名, 权限, 时间, 扩展属性), 元数据与文件内容一同加密
+	Metadata bool `json:"metadata,omitempty"`
+
+	// raw 魔数, 头部长度与头部内容的原始字节, mac 为其 MAC, 由 Write/Read 设置
+	raw []byte
+	mac []byte
+}
+
+// Stanza 一个接收者的文件密钥包装数据.
+// standard 格式的文件密钥存储于加密数据中, Stanza 只记录接收者信息, Key 为空.
+type Stanza struct {
+	Type string `json:"type"`
+	// Fingerprint 接收者公钥指纹, 用于解密时查找匹配的接收者
+	Fingerprint string `json:"fingerprint,omitempty"`
+	KDF         *KDF   `json:"kdf,omitempty"`
+	// EphemeralKey 密钥协商类型的临时公钥
+	EphemeralKey []byte `json:"epk,omitempty"`
+	// Ciphertext 密钥封装(KEM)类型的封装密文
+	Ciphertext []byte `json:"ct,omitempty"`
+	Key        []byte `json:"key,omitempty"`
+}
+
+// KDF 口令派生密钥的参数, 仅口令类型的 Stanza 使用
+type KDF struct {
+	Salt []byte `json:"salt"`
+	// scrypt 参数, N = 2^LogN
+	LogN int `json:"logN,omitempty"`
+	R    int `json:"r,omitempty"`
+	P    int `json:"p,omitempty"`
+	// Argon2id 参数, Memory 单位 KiB
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// Signature 对明文摘要的签名
+type Signature struct {
+	Algorithm string `json:"alg"`
+	// Fingerprint 签名者公钥指纹
+	Fingerprint string `json:"fingerprint"`
+	// PublicKey 签名者 PEM 格式公钥, 嵌入加密文件时用于验证签名
+	PublicKey []byte `json:"public_key,omitempty"`
+	Signature []byte `json:"sig"`
+}
+
+// Write 将文件头写入 w. 版本 7 起在头部内容之后写入 MAC, MAC 密钥由文件密钥 key 派生, 见 Header.Verify
+func Write(w io.Writer, h *Header, key []byte) error {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxSize {
+		return fmt.Errorf("header: size %d exceeds %d", len(body), maxSize)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(Magic)+4+len(body)+MACSize))
+	buf.WriteString(Magic)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(body)))
+	buf.Write(body)
+	h.raw = append(h.raw[:0], buf.Bytes()...)
+	if h.Authenticated() {
+		if h.mac, err = computeMAC(key, h.raw); err != nil {
+			return err
+		}
+		buf.Write(h.mac)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Authenticated 文件头是否带有 MAC, 版本 7 之前的文件头未认证
+func (h *Header) Authenticated() bool {
+	return h.Version >= MACVersion
+}
+
+// MAC 返回文件头的 MAC, 未认证的文件头返回 nil
+func (h *Header) MAC() []byte {
+	return h.mac
+}
+
+// Verify 使用文件密钥 key 校验文件头的 MAC, 须在使用除 Format, Recipients 以外的字段之前调用.
+// MAC 覆盖魔数, 长度与头部内容的原始字节, 任何修改都返回 ErrBadMAC; 未认证的文件头返回 ErrNoMAC.
+func (h *Header) Verify(key []byte) error {
+	if !h.Authenticated() {
+		return ErrNoMAC
+	}
+	mac, err := computeMAC(key, h.raw)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, h.mac) {
+		return ErrBadMAC
+	}
+	return nil
+}
+
+// computeMAC 计算 HMAC-SHA256(HKDF-SHA256(key, macInfo), raw)
+func computeMAC(key, raw []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("header: empty MAC key")
+	}
+	macKey := make([]byte, MACSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(macInfo)), macKey); err != nil {
+		return nil, err
+	}
+	m := hmac.New(sha256.New, macKey)
+	m.Write(raw)
+	return m.Sum(nil), nil
+}
+
+// Detect 判断 r 是否以文件头魔数开头, 会读取 r 开头的 len(Magic) 字节
+func Detect(r io.Reader) (bool, error) {
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(magic) == Magic, nil
+}
+
+// Read 从 r 读取文件头, 仅读取文件头本身, 之后的数据保持未读
+func Read(r io.Reader) (*Header, error) {
+	prefix := make([]byte, len(Magic)+4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNoHeader
+		}
+		return nil, err
+	}
+	if string(prefix[:len(Magic)]) != Magic {
+		return nil, ErrNoHeader
+	}
+	n := binary.BigEndian.Uint32(prefix[len(Magic):])
+	if n > maxSize {
+		return nil, fmt.Errorf("header: size %d exceeds %d", n, maxSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("header: read body: %w", err)
+	}
+
+	h := &Header{}
+	if err := json.Unmarshal(body, h); err != nil {
+		return nil, fmt.Errorf("header: parse body: %w", err)
+	}
+	if h.Version < 1 || h.Version > Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	h.raw = append(prefix, body...)
+	if h.Authenticated() {
+		h.mac = make([]byte, MACSize)
+		if _, err := io.ReadFull(r, h.mac); err != nil {
+			return nil, fmt.Errorf("header: read MAC: %w", err)
+		}
+	}
+	return h, nil
+}
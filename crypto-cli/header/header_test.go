@@ -0,0 +1,125 @@
+package header
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestWriteRead(t *testing.T) {
+	h := &Header{
+		Version: Version,
+		Format:  FormatStandard,
+		Cipher:  "aes-256-ctr",
+		Hash:    "sha256",
+		Recipients: []Stanza{
+			{Type: StanzaRSA, Key: []byte{0x1, 0x2, 0x3}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, h, testKey); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf.WriteString("payload")
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("Read() = %+v, want %+v", got, h)
+	}
+	if err := got.Verify(testKey); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if buf.String() != "payload" {
+		t.Errorf("Read() consumed payload, left %q", buf.String())
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrNoHeader},
+		{name: "no-magic", data: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b}, want: ErrNoHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	_ = Write(&buf, &Header{Version: Version + 1, Format: FormatStream}, testKey)
+	if _, err := Read(&buf); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Read() error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	h := &Header{Version: Version, Format: FormatStream, Compression: "gzip", Metadata: true}
+	var buf bytes.Buffer
+	if err := Write(&buf, h, testKey); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+		key    []byte
+		want   error
+	}{
+		{name: "valid", modify: func(b []byte) []byte { return b }, key: testKey, want: nil},
+		{name: "wrong-key", modify: func(b []byte) []byte { return b }, key: []byte("another file key"), want: ErrBadMAC},
+		{name: "strip-compression", modify: func(b []byte) []byte {
+			return rewriteBody(b, `"compression":"gzip",`, "")
+		}, key: testKey, want: ErrBadMAC},
+		{name: "change-compression", modify: func(b []byte) []byte {
+			return rewriteBody(b, `"gzip"`, `"zstd"`)
+		}, key: testKey, want: ErrBadMAC},
+		{name: "flip-metadata", modify: func(b []byte) []byte {
+			return rewriteBody(b, `"metadata":true`, `"metadata":false`)
+		}, key: testKey, want: ErrBadMAC},
+		{name: "flip-mac", modify: func(b []byte) []byte {
+			b[len(b)-1] ^= 1
+			return b
+		}, key: testKey, want: ErrBadMAC},
+		{name: "downgrade", modify: func(b []byte) []byte {
+			return rewriteBody(b, `"version":7`, `"version":6`)
+		}, key: testKey, want: ErrNoMAC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.modify(append([]byte{}, valid...))
+			got, err := Read(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if err := got.Verify(tt.key); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// rewriteBody 替换文件头内容中的 old 为 new 并修正头部长度, 模拟篡改文件头
+func rewriteBody(b []byte, old, new string) []byte {
+	n := binary.BigEndian.Uint32(b[len(Magic):])
+	start := len(Magic) + 4
+	body := strings.Replace(string(b[start:start+int(n)]), old, new, 1)
+	out := append([]byte{}, b[:start]...)
+	binary.BigEndian.PutUint32(out[len(Magic):], uint32(len(body)))
+	out = append(out, body...)
+	return append(out, b[start+int(n):]...)
+}
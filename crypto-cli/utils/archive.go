@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveTar 目录归档格式
+const ArchiveTar = "tar"
+
+// ErrUnsafePath 归档中的路径为绝对路径或包含 .., 解压时可能写到目标目录之外
+var ErrUnsafePath = errors.New("unsafe path in archive")
+
+// WriteArchive 将目录 root 下的普通文件以 tar 格式写入 w, 保留相对路径, 权限与修改时间.
+// include/exclude 规则同 WalkFiles, 空目录, 符号链接等非普通文件不归档.
+func WriteArchive(w io.Writer, root string, include, exclude []string) error {
+	files, err := WalkFiles(root, include, exclude)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	dirs := make(map[string]bool)
+	for _, rel := range files {
+		if err := writeArchiveDirs(tw, root, path.Dir(rel), dirs); err != nil {
+			return err
+		}
+		if err := writeArchiveFile(tw, root, rel); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeArchiveDirs 写入目录 rel 及其尚未写入的上级目录
+func writeArchiveDirs(tw *tar.Writer, root, rel string, dirs map[string]bool) error {
+	if rel == "." || dirs[rel] {
+		return nil
+	}
+	if err := writeArchiveDirs(tw, root, path.Dir(rel), dirs); err != nil {
+		return err
+	}
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel + "/"
+	dirs[rel] = true
+	return tw.WriteHeader(hdr)
+}
+
+func writeArchiveFile(tw *tar.Writer, root, rel string) error {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// 归档期间文件被截断时 tar 会报错, 变长时只写入 hdr.Size 字节
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// ExtractArchive 将 tar 格式的 r 解压到目录 dir, 返回解压的文件数.
+// 拒绝绝对路径与包含 .. 的路径; 只解压普通文件与目录, 其他类型(符号链接, 设备文件等)跳过, 避免写到 dir 之外.
+// dir 应为新建的空目录, 其中已有的符号链接可能被跟随. 读到 tar 结束标记后会读完 r 的剩余数据.
+func ExtractArchive(r io.Reader, dir string) (int, error) {
+	tr := tar.NewReader(r)
+	n := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, `\`) {
+			return n, fmt.Errorf("%w: %s", ErrUnsafePath, hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return n, err
+			}
+			if err := os.Chmod(target, mode|0700); err != nil {
+				return n, err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tr, target, mode); err != nil {
+				return n, err
+			}
+			if err := os.Chtimes(target, hdr.AccessTime, hdr.ModTime); err != nil {
+				return n, err
+			}
+			n++
+		default:
+			log.Printf("[INFO] skip %s in archive, type:%c", hdr.Name, hdr.Typeflag)
+		}
+	}
+	_, err := io.Copy(io.Discard, r)
+	return n, err
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
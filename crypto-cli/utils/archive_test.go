@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/deep/c.log": "c"}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, root, nil, []string{"*.log"}); err != nil {
+		t.Fatalf("WriteArchive() error = %v", err)
+	}
+	dir := t.TempDir()
+	n, err := ExtractArchive(&buf, dir)
+	if err != nil {
+		t.Fatalf("ExtractArchive() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ExtractArchive() = %d files, want 2", n)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		got, err := os.ReadFile(p)
+		if err != nil || string(got) != files[name] {
+			t.Errorf("extracted %s = %q, %v, want %q", name, got, err, files[name])
+		}
+		if info, _ := os.Stat(p); info.Mode().Perm() != 0640 {
+			t.Errorf("extracted %s mode = %v, want 0640", name, info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "deep", "c.log")); !os.IsNotExist(err) {
+		t.Errorf("excluded file extracted, err = %v", err)
+	}
+}
+
+func TestExtractArchiveUnsafe(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/evil", "a/../../evil"} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+			_, _ = tw.Write([]byte("x"))
+			_ = tw.Close()
+
+			if _, err := ExtractArchive(&buf, t.TempDir()); !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("ExtractArchive() error = %v, want %v", err, ErrUnsafePath)
+			}
+		})
+	}
+
+	// 符号链接被跳过
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	_ = tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc", Typeflag: tar.TypeSymlink})
+	_ = tw.Close()
+	dir := t.TempDir()
+	if _, err := ExtractArchive(&buf, dir); err != nil {
+		t.Fatalf("ExtractArchive() error = %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "link")); !os.IsNotExist(err) {
+		t.Errorf("symlink extracted, err = %v", err)
+	}
+}
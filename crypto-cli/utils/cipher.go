@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	stdaes "crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/jan-bar/EncryptionFile"
+	"go-crypto/aes"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// standard 格式(文件头版本 7 起)的文件密钥.
+// EncryptionFile 以 RSA 加密 密钥 + 0 + iv/nonce, 旧版本直接使用 32 字节的 AES 密钥;
+// 版本 7 改为 KeyMaterialSize 字节的密钥材料, AES 密钥由密钥材料与头部 MAC 经 HKDF 派生, 使加密数据与文件头绑定.
+// 密钥材料比 AES 密钥长 1 字节, 旧版本程序以及无文件头/旧版本文件头的解密路径均以 key error 拒绝,
+// 不能通过删除 MAC 并降低文件头版本绕过头部认证.
+
+// KeyMaterialSize standard 格式文件密钥材料的长度
+const KeyMaterialSize = 33
+
+// payloadInfo 派生 standard 格式 AES 密钥时 HKDF 的 info
+const payloadInfo = "crypto-cli standard payload"
+
+// NewKeyMaterial 随机生成 standard 格式的文件密钥材料, 不含 0 字节(0 为密钥材料与 iv 的分隔符).
+// 为 0 的字节重新随机生成, 每个字节在 1-255 中均匀分布.
+func NewKeyMaterial() ([]byte, error) {
+	material := make([]byte, KeyMaterialSize)
+	if _, err := io.ReadFull(rand.Reader, material); err != nil {
+		return nil, err
+	}
+	for i := range material {
+		for material[i] == 0 {
+			if _, err := io.ReadFull(rand.Reader, material[i:i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return material, nil
+}
+
+// SplitKeyData 拆分 RSA 解密得到的 密钥材料 + 0 + iv/nonce, 密钥材料长度不是 KeyMaterialSize 时返回错误
+func SplitKeyData(data []byte) (material, iv []byte, err error) {
+	material, iv, ok := bytes.Cut(data, []byte{0})
+	if !ok || len(material) != KeyMaterialSize {
+		return nil, nil, errors.New("invalid key material")
+	}
+	return material, iv, nil
+}
+
+// PayloadKey 由密钥材料与头部 MAC 派生 standard 格式的 AES-256 密钥
+func PayloadKey(material, mac []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, material, mac, []byte(payloadInfo)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewEncCipher 返回 standard 格式(版本 7)的 EncryptionFile.EncCipher, 使用 material 与头部 MAC 派生的密钥
+func NewEncCipher(security string, material, mac []byte) EncryptionFile.EncCipher {
+	return func() ([]byte, any, error) {
+		mode, block, err := payloadBlock(security, material, mac)
+		if err != nil {
+			return nil, nil, err
+		}
+		size := block.BlockSize()
+		if mode == aes.ModeGCM {
+			size = aes.GCMStandardNonceSize
+		}
+		iv := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+			return nil, nil, err
+		}
+		gen, err := modeCipher(mode, block, iv, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		data := append(append(append([]byte{}, material...), 0), iv...)
+		return data, gen, nil
+	}
+}
+
+// NewDecCipher 返回 standard 格式(版本 7)的 EncryptionFile.DecCipher, mac 为已校验的头部 MAC
+func NewDecCipher(security string, mac []byte) EncryptionFile.DecCipher {
+	return func(data []byte) (any, error) {
+		material, iv, err := SplitKeyData(data)
+		if err != nil {
+			return nil, err
+		}
+		mode, block, err := payloadBlock(security, material, mac)
+		if err != nil {
+			return nil, err
+		}
+		return modeCipher(mode, block, iv, false)
+	}
+}
+
+// CTRPayloadEncryptor 返回 standard 格式(版本 7) CTR 模式的解密器, 用于随机访问解密
+func CTRPayloadEncryptor(data, mac []byte) (*aes.Encryptor, error) {
+	material, iv, err := SplitKeyData(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := PayloadKey(material, mac)
+	if err != nil {
+		return nil, err
+	}
+	e := aes.NewEncryptor(key, aes.ModeCTR)
+	if err := e.SetIV(iv); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// payloadBlock 解析 security 中的加密模式, 并以派生的密钥创建 AES 分组密码
+func payloadBlock(security string, material, mac []byte) (aes.Mode, cipher.Block, error) {
+	securities := strings.Split(security, "-")
+	if len(securities) != 3 {
+		return "", nil, fmt.Errorf("invalid security:%s", security)
+	}
+	key, err := PayloadKey(material, mac)
+	if err != nil {
+		return "", nil, err
+	}
+	block, err := stdaes.NewCipher(key)
+	if err != nil {
+		return "", nil, err
+	}
+	return aes.Mode(strings.ToUpper(securities[2])), block, nil
+}
+
+// modeCipher 按加密模式返回 EncryptionFile 接受的 cipher.AEAD, cipher.Stream 或 cipher.BlockMode
+func modeCipher(mode aes.Mode, block cipher.Block, iv []byte, encrypt bool) (any, error) {
+	if mode == aes.ModeGCM {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != aead.NonceSize() {
+			return nil, errors.New("len(nonce) error")
+		}
+		return aead, nil
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, errors.New("len(iv) error")
+	}
+	switch mode {
+	case aes.ModeCBC:
+		if encrypt {
+			return cipher.NewCBCEncrypter(block, iv), nil
+		}
+		return cipher.NewCBCDecrypter(block, iv), nil
+	case aes.ModeCFB:
+		if encrypt {
+			return cipher.NewCFBEncrypter(block, iv), nil
+		}
+		return cipher.NewCFBDecrypter(block, iv), nil
+	case aes.ModeCTR:
+		return cipher.NewCTR(block, iv), nil
+	case aes.ModeOFB:
+		return cipher.NewOFB(block, iv), nil
+	default:
+		return nil, fmt.Errorf("invalid security mode:%s", mode)
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"go-crypto/crypto-cli/config"
+	"os"
+	"testing"
+
+	"github.com/jan-bar/EncryptionFile"
+)
+
+func TestPayloadCipher(t *testing.T) {
+	pubKey, err := os.ReadFile("../public.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priKey, err := os.ReadFile("../private.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("payload cipher "), 5000)
+	mac := bytes.Repeat([]byte{0x5a}, 32)
+
+	for _, security := range []string{"aes-256-cbc", "aes-256-cfb", "aes-256-ctr", "aes-256-ofb", "aes-256-gcm"} {
+		t.Run(security, func(t *testing.T) {
+			material, err := NewKeyMaterial()
+			if err != nil {
+				t.Fatalf("NewKeyMaterial() error = %v", err)
+			}
+			if bytes.IndexByte(material, 0) >= 0 {
+				t.Fatalf("NewKeyMaterial() contains 0")
+			}
+			var enc bytes.Buffer
+			if err := EncryptionFile.EncData(bytes.NewReader(data), &enc, pubKey, sha256.New(), NewEncCipher(security, material, mac)); err != nil {
+				t.Fatalf("EncData() error = %v", err)
+			}
+
+			var dec bytes.Buffer
+			if err := EncryptionFile.DecData(bytes.NewReader(enc.Bytes()), &dec, priKey, sha256.New(), NewDecCipher(security, mac)); err != nil {
+				t.Fatalf("DecData() error = %v", err)
+			}
+			if !bytes.Equal(dec.Bytes(), data) {
+				t.Errorf("DecData() plaintext mismatch")
+			}
+
+			// 旧版本的解密路径(无文件头或版本 7 之前的文件头)必须拒绝, 不能绕过头部 MAC
+			legacy := InitDecCipher(&config.Config{Security: security})
+			if err := EncryptionFile.DecData(bytes.NewReader(enc.Bytes()), &bytes.Buffer{}, priKey, sha256.New(), legacy); err == nil {
+				t.Errorf("legacy DecData() want error")
+			}
+		})
+	}
+
+	// 头部 MAC 不同时派生的密钥不同, GCM 认证失败
+	material, _ := NewKeyMaterial()
+	var enc bytes.Buffer
+	if err := EncryptionFile.EncData(bytes.NewReader(data), &enc, pubKey, sha256.New(), NewEncCipher("aes-256-gcm", material, mac)); err != nil {
+		t.Fatalf("EncData() error = %v", err)
+	}
+	other := bytes.Repeat([]byte{0xa5}, 32)
+	if err := EncryptionFile.DecData(bytes.NewReader(enc.Bytes()), &bytes.Buffer{}, priKey, sha256.New(), NewDecCipher("aes-256-gcm", other)); err == nil {
+		t.Errorf("DecData() with another header MAC want error")
+	}
+}
+
+func TestSplitKeyData(t *testing.T) {
+	material := bytes.Repeat([]byte{0x1}, KeyMaterialSize)
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", append(append(append([]byte{}, material...), 0), 0x2, 0x3), false},
+		{"legacy-key", append(append(bytes.Repeat([]byte{0x1}, 32), 0), 0x2), true},
+		{"no-separator", material, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := SplitKeyData(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SplitKeyData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
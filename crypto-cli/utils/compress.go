@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 加密前的压缩算法
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Compressions 支持的压缩算法
+var Compressions = map[string]struct{}{
+	CompressNone: {},
+	CompressGzip: {},
+	CompressZstd: {},
+}
+
+// NewCompressor 返回按 name 压缩后写入 w 的 Writer, name 为空或 none 时不压缩. 须 Close 以写出剩余数据, 不会关闭 w
+func NewCompressor(w io.Writer, name string) (io.WriteCloser, error) {
+	switch name {
+	case "", CompressNone:
+		return nopWriteCloser{w}, nil
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("unsupported compression:%s", name)
+}
+
+// NewDecompressor 返回从 r 读取并按 name 解压的 Reader, name 为空或 none 时不解压. 须 Close 释放资源, 不会关闭 r
+func NewDecompressor(r io.Reader, name string) (io.ReadCloser, error) {
+	switch name {
+	case "", CompressNone:
+		return io.NopCloser(r), nil
+	case CompressGzip:
+		return gzip.NewReader(r)
+	case CompressZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported compression:%s", name)
+}
+
+// NewCompressReader 返回读取 r 并按 name 压缩后的 Reader, 压缩在单独的 goroutine 中进行.
+// 须 Close 以结束压缩 goroutine, 不会关闭 r
+func NewCompressReader(r io.Reader, name string) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	cw, err := NewCompressor(pw, name)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(cw, r)
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// NewDecompressWriter 返回按 name 解压后写入 w 的 Writer, 解压在单独的 goroutine 中进行.
+// 须 Close 以等待剩余数据写出, Close 返回解压或写入 w 的错误, 不会关闭 w
+func NewDecompressWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := func() error {
+			dr, err := NewDecompressor(pr, name)
+			if err != nil {
+				return err
+			}
+			defer dr.Close()
+			_, err = io.Copy(w, dr)
+			return err
+		}()
+		pr.CloseWithError(err)
+		done <- err
+	}()
+	return &decompressWriter{PipeWriter: pw, done: done}, nil
+}
+
+type decompressWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (d *decompressWriter) Close() error {
+	d.PipeWriter.Close()
+	return <-d.done
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
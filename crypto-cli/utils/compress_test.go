@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte("go-crypto compress "), 1000)
+	for _, name := range []string{"", CompressNone, CompressGzip, CompressZstd} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewCompressor(&buf, name)
+			if err != nil {
+				t.Fatalf("NewCompressor() error = %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if name != "" && name != CompressNone && buf.Len() >= len(data) {
+				t.Errorf("compressed size %d, want < %d", buf.Len(), len(data))
+			}
+
+			r, err := NewDecompressor(&buf, name)
+			if err != nil {
+				t.Fatalf("NewDecompressor() error = %v", err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("decompressed data mismatch")
+			}
+
+			// 流式压缩与解压
+			cr, err := NewCompressReader(bytes.NewReader(data), name)
+			if err != nil {
+				t.Fatalf("NewCompressReader() error = %v", err)
+			}
+			defer cr.Close()
+			var out bytes.Buffer
+			dw, err := NewDecompressWriter(&out, name)
+			if err != nil {
+				t.Fatalf("NewDecompressWriter() error = %v", err)
+			}
+			if _, err := io.Copy(dw, cr); err != nil {
+				t.Fatalf("Copy() error = %v", err)
+			}
+			if err := dw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Errorf("stream decompressed data mismatch")
+			}
+		})
+	}
+
+	// 损坏的压缩数据在 Close 时返回错误
+	dw, _ := NewDecompressWriter(io.Discard, CompressGzip)
+	_, _ = dw.Write([]byte("not gzip data"))
+	if err := dw.Close(); err == nil {
+		t.Errorf("NewDecompressWriter() corrupt data error = nil")
+	}
+
+	if _, err := NewCompressor(io.Discard, "lz4"); err == nil {
+		t.Errorf("NewCompressor() unsupported error = nil")
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// standard 格式的自校验哈希
+const (
+	// HashMD5 旧版本文件(无文件头)使用的哈希, 只用于解密
+	HashMD5     = "md5"
+	HashSHA256  = "sha256"
+	HashSHA512  = "sha512"
+	HashBLAKE2b = "blake2b"
+	HashSHA3256 = "sha3-256"
+)
+
+// ErrChecksum 数据末尾的自校验哈希与数据不一致
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Hashes 加密时可选的自校验哈希
+var Hashes = map[string]func() hash.Hash{
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+	HashBLAKE2b: func() hash.Hash {
+		h, _ := blake2b.New512(nil)
+		return h
+	},
+	HashSHA3256: func() hash.Hash {
+		return sha3.New256()
+	},
+}
+
+// NewHash 按名称返回自校验哈希, 除 Hashes 外还支持读取旧版本文件的 md5
+func NewHash(name string) (hash.Hash, error) {
+	if name == HashMD5 {
+		return md5.New(), nil
+	}
+	newHash, ok := Hashes[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash:%s", name)
+	}
+	return newHash(), nil
+}
+
+// CheckSum 校验 r 中数据末尾 h.Size() 字节的自校验哈希是否与之前全部数据的哈希 h 一致,
+// 用于不解密验证 standard 格式加密数据的完整性
+func CheckSum(r io.Reader, h hash.Hash) error {
+	size := h.Size()
+	buf := make([]byte, 32*1024+size)
+	n := 0
+	for {
+		m, err := r.Read(buf[n:])
+		n += m
+		// 保留末尾 size 字节, 其余计入哈希
+		if n > size {
+			h.Write(buf[:n-size])
+			n = copy(buf, buf[n-size:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if n < size || !bytes.Equal(h.Sum(nil), buf[:size]) {
+		return ErrChecksum
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewHash(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // 对 "abc" 的哈希值前缀
+	}{
+		{HashMD5, "900150983cd24fb0"},
+		{HashSHA256, "ba7816bf8f01cfea"},
+		{HashSHA512, "ddaf35a193617aba"},
+		{HashBLAKE2b, "ba80a53f981c4d0d"},
+		{HashSHA3256, "3a985da74fe225b2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewHash(tt.name)
+			if err != nil {
+				t.Fatalf("NewHash() error = %v", err)
+			}
+			h.Write([]byte("abc"))
+			if got := hex.EncodeToString(h.Sum(nil))[:16]; got != tt.want {
+				t.Errorf("NewHash() sum = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewHash("sha1"); err == nil {
+		t.Errorf("NewHash() unsupported hash want error")
+	}
+	if _, ok := Hashes[HashMD5]; ok {
+		t.Errorf("md5 must not be selectable for new files")
+	}
+}
+
+func TestCheckSum(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	h, _ := NewHash(HashSHA256)
+	h.Write(data)
+	valid := append(append([]byte{}, data...), h.Sum(nil)...)
+
+	tampered := append([]byte{}, valid...)
+	tampered[100] ^= 1
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"valid", valid, nil},
+		{"tampered", tampered, ErrChecksum},
+		{"truncated", valid[:len(valid)-1], ErrChecksum},
+		{"short", valid[:10], ErrChecksum},
+		{"empty", nil, ErrChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := NewHash(HashSHA256)
+			// 每次只读取 1 字节, 覆盖哈希跨越多次读取的情况
+			if err := CheckSum(iotest.OneByteReader(bytes.NewReader(tt.data)), h); err != tt.want {
+				t.Errorf("CheckSum() error = %v, want %v", err, tt.want)
+			}
+			h.Reset()
+			if err := CheckSum(bytes.NewReader(tt.data), h); err != tt.want {
+				t.Errorf("CheckSum() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
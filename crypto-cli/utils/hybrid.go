@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// ML-KEM-768 + X25519 混合密钥封装:
+// 分别通过 ML-KEM-768 封装与 X25519 ECDH 得到两个共享密钥, 经 HKDF-SHA256 合并派生包装密钥,
+// 只要其中一种算法未被攻破, 包装密钥即是安全的.
+//
+// 公钥 PEM 内容: ML-KEM-768 封装密钥(1184) | X25519 公钥(32)
+// 私钥 PEM 内容: ML-KEM-768 种子(64) | X25519 私钥(32)
+
+const (
+	hybridPublicKeyType  = "CRYPTO-CLI MLKEM768X25519 PUBLIC KEY"
+	hybridPrivateKeyType = "CRYPTO-CLI MLKEM768X25519 PRIVATE KEY"
+	hybridInfo           = "go-crypto/mlkem768x25519"
+
+	x25519KeySize = 32
+)
+
+// GenHybridKey 生成 ML-KEM-768 + X25519 混合密钥对, 均为 PEM 格式
+func GenHybridKey(pub, pri io.Writer) error {
+	dk, err := mlkem.GenerateKey768()
+	if err != nil {
+		return err
+	}
+	xk, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(pri, &pem.Block{
+		Type:  hybridPrivateKeyType,
+		Bytes: append(dk.Bytes(), xk.Bytes()...),
+	}); err != nil {
+		return err
+	}
+	return pem.Encode(pub, &pem.Block{
+		Type:  hybridPublicKeyType,
+		Bytes: append(dk.EncapsulationKey().Bytes(), xk.PublicKey().Bytes()...),
+	})
+}
+
+// HybridRecipient 使用 ML-KEM-768 + X25519 混合密钥封装包装文件密钥
+type HybridRecipient struct {
+	PubKey []byte
+}
+
+func (r *HybridRecipient) Wrap(fileKey []byte) (*header.Stanza, error) {
+	ek, pub, err := parseHybridPublicKey(r.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := Fingerprint(r.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	kemShared, ct := ek.Encapsulate()
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ecdhShared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	epk := ephemeral.PublicKey().Bytes()
+	kek, err := hybridKEK(kemShared, ecdhShared, ct, epk, pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := WrapKey(kek, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &header.Stanza{
+		Type:         header.StanzaMLKEM768X25519,
+		Fingerprint:  fp,
+		EphemeralKey: epk,
+		Ciphertext:   ct,
+		Key:          encKey,
+	}, nil
+}
+
+// HybridIdentity 使用 ML-KEM-768 + X25519 混合私钥解出文件密钥
+type HybridIdentity struct {
+	PriKey []byte
+}
+
+func (i *HybridIdentity) Unwrap(stanzas []header.Stanza) ([]byte, error) {
+	dk, key, err := parseHybridPrivateKey(i.PriKey)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := PrivateKeyFingerprint(i.PriKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stanzas {
+		if s.Type != header.StanzaMLKEM768X25519 || s.Fingerprint != fp {
+			continue
+		}
+		kemShared, err := dk.Decapsulate(s.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ml-kem ciphertext: %w", err)
+		}
+		epk, err := ecdh.X25519().NewPublicKey(s.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x25519 ephemeral key: %w", err)
+		}
+		ecdhShared, err := key.ECDH(epk)
+		if err != nil {
+			return nil, err
+		}
+		kek, err := hybridKEK(kemShared, ecdhShared, s.Ciphertext, s.EphemeralKey, key.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		fileKey, err := UnwrapKey(kek, s.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap file key: %w", err)
+		}
+		return fileKey, nil
+	}
+	return nil, ErrNoIdentity
+}
+
+// hybridKEK 合并两个共享密钥派生包装密钥, 盐为封装密文, 临时公钥与接收者 X25519 公钥
+func hybridKEK(kemShared, ecdhShared, ct, epk, recipient []byte) ([]byte, error) {
+	secret := append(append([]byte{}, kemShared...), ecdhShared...)
+	salt := append(append(append([]byte{}, ct...), epk...), recipient...)
+	kek := make([]byte, kdfKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(hybridInfo)), kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+func parseHybridPublicKey(pubKey []byte) (*mlkem.EncapsulationKey768, *ecdh.PublicKey, error) {
+	block, _ := pem.Decode(pubKey)
+	if block == nil || block.Type != hybridPublicKeyType {
+		return nil, nil, errors.New("invalid mlkem768x25519 public key pem")
+	}
+	if len(block.Bytes) != mlkem.EncapsulationKeySize768+x25519KeySize {
+		return nil, nil, fmt.Errorf("invalid mlkem768x25519 public key length:%d", len(block.Bytes))
+	}
+	ek, err := mlkem.NewEncapsulationKey768(block.Bytes[:mlkem.EncapsulationKeySize768])
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := ecdh.X25519().NewPublicKey(block.Bytes[mlkem.EncapsulationKeySize768:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return ek, pub, nil
+}
+
+func parseHybridPrivateKey(priKey []byte) (*mlkem.DecapsulationKey768, *ecdh.PrivateKey, error) {
+	block, _ := pem.Decode(priKey)
+	if block == nil || block.Type != hybridPrivateKeyType {
+		return nil, nil, errors.New("invalid mlkem768x25519 private key pem")
+	}
+	if len(block.Bytes) != mlkem.SeedSize+x25519KeySize {
+		return nil, nil, fmt.Errorf("invalid mlkem768x25519 private key length:%d", len(block.Bytes))
+	}
+	dk, err := mlkem.NewDecapsulationKey768(block.Bytes[:mlkem.SeedSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(block.Bytes[mlkem.SeedSize:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return dk, key, nil
+}
+
+// hybridPublicKeyBytes 返回混合私钥对应的公钥内容
+func hybridPublicKeyBytes(priKey []byte) ([]byte, error) {
+	dk, key, err := parseHybridPrivateKey(priKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(dk.EncapsulationKey().Bytes(), key.PublicKey().Bytes()...), nil
+}
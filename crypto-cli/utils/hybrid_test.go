@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"testing"
+)
+
+func TestHybridRecipients(t *testing.T) {
+	dir := t.TempDir()
+	pubA, priA, err := GenKeyFiles(&KeyOptions{Type: KeyTypeHybrid, Dir: dir, Name: "a"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	pubB, priB, err := GenKeyFiles(&KeyOptions{Type: KeyTypeHybrid, Dir: dir, Name: "b"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	if kt, err := KeyType(pubA); err != nil || kt != KeyTypeHybrid {
+		t.Errorf("KeyType() = %s, %v, want %s", kt, err, KeyTypeHybrid)
+	}
+	fpPub, _ := Fingerprint(pubA)
+	fpPri, err := PrivateKeyFingerprint(priA)
+	if err != nil || fpPub != fpPri {
+		t.Errorf("PrivateKeyFingerprint() = %s, %v, want %s", fpPri, err, fpPub)
+	}
+
+	fileKey := bytes.Repeat([]byte{0x7}, 32)
+	var stanzas []header.Stanza
+	for _, pubKey := range [][]byte{pubA, pubB} {
+		r, err := NewRecipient(pubKey)
+		if err != nil {
+			t.Fatalf("NewRecipient() error = %v", err)
+		}
+		if _, ok := r.(*HybridRecipient); !ok {
+			t.Fatalf("NewRecipient() = %T, want *HybridRecipient", r)
+		}
+		s, err := r.Wrap(fileKey)
+		if err != nil {
+			t.Fatalf("Wrap() error = %v", err)
+		}
+		stanzas = append(stanzas, *s)
+	}
+	for name, priKey := range map[string][]byte{"a": priA, "b": priB} {
+		id, err := NewIdentity(priKey)
+		if err != nil {
+			t.Fatalf("NewIdentity() %s error = %v", name, err)
+		}
+		got, err := id.Unwrap(stanzas)
+		if err != nil {
+			t.Fatalf("Unwrap() %s error = %v", name, err)
+		}
+		if !bytes.Equal(got, fileKey) {
+			t.Errorf("Unwrap() %s = %x, want %x", name, got, fileKey)
+		}
+	}
+
+	if _, err := (&HybridIdentity{PriKey: priB}).Unwrap(stanzas[:1]); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("Unwrap() without matching recipient error = %v, want %v", err, ErrNoIdentity)
+	}
+
+	// 篡改封装密文或临时公钥后均无法解出文件密钥
+	tampered := stanzas[0]
+	tampered.Ciphertext = append([]byte{}, tampered.Ciphertext...)
+	tampered.Ciphertext[0] ^= 0x1
+	if _, err := (&HybridIdentity{PriKey: priA}).Unwrap([]header.Stanza{tampered}); err == nil {
+		t.Errorf("Unwrap() tampered ciphertext want error")
+	}
+	tampered = stanzas[0]
+	tampered.EphemeralKey = append([]byte{}, tampered.EphemeralKey...)
+	tampered.EphemeralKey[0] ^= 0x1
+	if _, err := (&HybridIdentity{PriKey: priA}).Unwrap([]header.Stanza{tampered}); err == nil {
+		t.Errorf("Unwrap() tampered ephemeral key want error")
+	}
+}
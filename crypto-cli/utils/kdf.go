@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go-crypto/aes"
+	"go-crypto/crypto-cli/header"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv 未指定口令文件时, 从该环境变量读取口令
+const PassphraseEnv = "CRYPTO_CLI_PASSPHRASE"
+
+const (
+	kdfSaltSize = 16
+	kdfKeySize  = 32
+
+	// scrypt 默认 N = 2^15, r = 8, p = 1, 约占用 32 MiB 内存
+	scryptDefaultLogN = 15
+	scryptMinLogN     = 10
+	scryptMaxLogN     = 22
+	scryptR           = 8
+	scryptP           = 1
+
+	// Argon2id 默认 3 轮, 64 MiB 内存, 4 线程
+	argon2DefaultTime = 3
+	argon2MinTime     = 1
+	argon2MaxTime     = 16
+	argon2Memory      = 64 * 1024
+	argon2MaxMemory   = 1024 * 1024
+	argon2Threads     = 4
+)
+
+// ErrWrongPassphrase 口令错误或文件密钥数据被篡改
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// NewKDF 生成随机盐并按 cost 设置派生参数, cost 为 0 时使用默认值.
+// scrypt 的 cost 为 log2(N), Argon2id 的 cost 为迭代轮数.
+func NewKDF(name string, cost int) (*header.KDF, error) {
+	kdf := &header.KDF{Salt: make([]byte, kdfSaltSize)}
+	if _, err := io.ReadFull(rand.Reader, kdf.Salt); err != nil {
+		return nil, err
+	}
+	switch name {
+	case header.StanzaScrypt:
+		if cost == 0 {
+			cost = scryptDefaultLogN
+		}
+		kdf.LogN, kdf.R, kdf.P = cost, scryptR, scryptP
+	case header.StanzaArgon2id:
+		if cost == 0 {
+			cost = argon2DefaultTime
+		}
+		kdf.Time, kdf.Memory, kdf.Threads = uint32(cost), argon2Memory, argon2Threads
+	default:
+		return nil, fmt.Errorf("invalid kdf:%s", name)
+	}
+	if err := checkKDF(name, kdf); err != nil {
+		return nil, err
+	}
+	return kdf, nil
+}
+
+// checkKDF 校验派生参数, 防止文件头中过大的参数耗尽资源
+func checkKDF(name string, kdf *header.KDF) error {
+	if len(kdf.Salt) < kdfSaltSize {
+		return fmt.Errorf("kdf salt too short:%d", len(kdf.Salt))
+	}
+	switch name {
+	case header.StanzaScrypt:
+		if kdf.LogN < scryptMinLogN || kdf.LogN > scryptMaxLogN {
+			return fmt.Errorf("scrypt cost must be in [%d, %d], got:%d", scryptMinLogN, scryptMaxLogN, kdf.LogN)
+		}
+		if kdf.R <= 0 || kdf.P <= 0 || kdf.R*kdf.P > 64 {
+			return fmt.Errorf("invalid scrypt parameters r:%d p:%d", kdf.R, kdf.P)
+		}
+	case header.StanzaArgon2id:
+		if kdf.Time < argon2MinTime || kdf.Time > argon2MaxTime {
+			return fmt.Errorf("argon2id cost must be in [%d, %d], got:%d", argon2MinTime, argon2MaxTime, kdf.Time)
+		}
+		if kdf.Memory < 8*uint32(kdf.Threads) || kdf.Memory > argon2MaxMemory || kdf.Threads == 0 {
+			return fmt.Errorf("invalid argon2id parameters memory:%d threads:%d", kdf.Memory, kdf.Threads)
+		}
+	default:
+		return fmt.Errorf("invalid kdf:%s", name)
+	}
+	return nil
+}
+
+// DeriveKey 按派生参数由口令派生出 32 字节密钥
+func DeriveKey(name string, kdf *header.KDF, passphrase []byte) ([]byte, error) {
+	if kdf == nil {
+		return nil, errors.New("missing kdf parameters")
+	}
+	if err := checkKDF(name, kdf); err != nil {
+		return nil, err
+	}
+	if name == header.StanzaScrypt {
+		return scrypt.Key(passphrase, kdf.Salt, 1<<kdf.LogN, kdf.R, kdf.P, kdfKeySize)
+	}
+	return argon2.IDKey(passphrase, kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, kdfKeySize), nil
+}
+
+// WrapKey 使用 AES-256-GCM 加密文件密钥, kek 每次均由随机盐派生, 因此使用全零 nonce
+func WrapKey(kek, fileKey []byte) ([]byte, error) {
+	e := aes.NewEncryptor(kek, aes.ModeGCM)
+	if err := e.SetIV(make([]byte, 16)); err != nil {
+		return nil, err
+	}
+	return e.Encrypt(fileKey)
+}
+
+// UnwrapKey 解密 WrapKey 加密的文件密钥
+func UnwrapKey(kek, wrapped []byte) ([]byte, error) {
+	e := aes.NewEncryptor(kek, aes.ModeGCM)
+	if err := e.SetIV(make([]byte, 16)); err != nil {
+		return nil, err
+	}
+	fileKey, err := e.Decrypt(wrapped)
+	if errors.Is(err, aes.ErrAuthFailed) {
+		return nil, ErrWrongPassphrase
+	}
+	return fileKey, err
+}
+
+// ReadPassphrase 读取口令, 依次尝试: 口令文件, 环境变量 env, 终端交互输入(不回显).
+// confirm 为 true 时终端输入需要确认两次.
+func ReadPassphrase(file, env, prompt string, confirm bool) ([]byte, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return checkPassphrase(bytes.TrimRight(data, "\r\n"))
+	}
+	if v, ok := os.LookupEnv(env); ok {
+		return checkPassphrase([]byte(v))
+	}
+
+	fd, closeFn, err := openTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to read passphrase, use passphrase file or %s: %w", env, err)
+	}
+	defer closeFn()
+
+	pass, err := readTerminal(fd, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		again, err := readTerminal(fd, "确认"+prompt)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	return checkPassphrase(pass)
+}
+
+func checkPassphrase(pass []byte) ([]byte, error) {
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return pass, nil
+}
+
+// openTerminal 优先使用标准输入, 标准输入不是终端时(如管道)打开控制终端
+func openTerminal() (fd int, closeFn func(), err error) {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		return fd, func() {}, nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(tty.Fd()), func() { tty.Close() }, nil
+}
+
+func readTerminal(fd int, prompt string) ([]byte, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", strings.TrimSpace(prompt))
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"testing"
+)
+
+func TestPassphraseRecipient(t *testing.T) {
+	fileKey := bytes.Repeat([]byte{0x5a}, 32)
+	tests := []struct {
+		name string
+		kdf  string
+		cost int
+	}{
+		{name: "scrypt", kdf: header.StanzaScrypt, cost: scryptMinLogN},
+		{name: "argon2id", kdf: header.StanzaArgon2id, cost: argon2MinTime},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &PassphraseRecipient{KDF: tt.kdf, Cost: tt.cost, Passphrase: []byte("correct horse")}
+			stanza, err := r.Wrap(fileKey)
+			if err != nil {
+				t.Fatalf("Wrap() error = %v", err)
+			}
+			if stanza.Type != tt.kdf || stanza.KDF == nil {
+				t.Fatalf("Wrap() stanza = %+v", stanza)
+			}
+			stanzas := []header.Stanza{{Type: header.StanzaRSA}, *stanza}
+
+			got, err := (&PassphraseIdentity{Passphrase: []byte("correct horse")}).Unwrap(stanzas)
+			if err != nil {
+				t.Fatalf("Unwrap() error = %v", err)
+			}
+			if !bytes.Equal(got, fileKey) {
+				t.Errorf("Unwrap() = %x, want %x", got, fileKey)
+			}
+
+			_, err = (&PassphraseIdentity{Passphrase: []byte("wrong horse")}).Unwrap(stanzas)
+			if !errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("Unwrap() wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+			}
+		})
+	}
+
+	if _, err := (&PassphraseIdentity{Passphrase: []byte("x")}).Unwrap([]header.Stanza{{Type: header.StanzaRSA}}); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("Unwrap() without passphrase stanza error = %v, want %v", err, ErrNoIdentity)
+	}
+}
+
+func TestDeriveKeyLimits(t *testing.T) {
+	salt := make([]byte, kdfSaltSize)
+	tests := []struct {
+		name string
+		kdf  string
+		p    *header.KDF
+	}{
+		{name: "scrypt-too-expensive", kdf: header.StanzaScrypt, p: &header.KDF{Salt: salt, LogN: scryptMaxLogN + 1, R: 8, P: 1}},
+		{name: "scrypt-too-cheap", kdf: header.StanzaScrypt, p: &header.KDF{Salt: salt, LogN: scryptMinLogN - 1, R: 8, P: 1}},
+		{name: "argon2id-too-much-memory", kdf: header.StanzaArgon2id, p: &header.KDF{Salt: salt, Time: 1, Memory: argon2MaxMemory + 1, Threads: 1}},
+		{name: "short-salt", kdf: header.StanzaArgon2id, p: &header.KDF{Salt: salt[:4], Time: 1, Memory: argon2Memory, Threads: 1}},
+		{name: "unknown", kdf: "pbkdf2", p: &header.KDF{Salt: salt}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DeriveKey(tt.kdf, tt.p, []byte("pass")); err == nil {
+				t.Errorf("DeriveKey() want error")
+			}
+		})
+	}
+}
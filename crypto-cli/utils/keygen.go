@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/jan-bar/EncryptionFile"
+	"os"
+	"path/filepath"
+)
+
+// 密钥类型
+const (
+	KeyTypeRSA    = "rsa"
+	KeyTypeX25519 = "x25519"
+	// KeyTypeHybrid ML-KEM-768 + X25519 混合密钥, 抵御"先存储后解密"的量子计算攻击
+	KeyTypeHybrid = "mlkem768x25519"
+	// KeyTypeEd25519 Ed25519 签名密钥, 只能用于签名
+	KeyTypeEd25519 = "ed25519"
+)
+
+// KeyTypes 支持的密钥类型
+var KeyTypes = map[string]struct{}{
+	KeyTypeRSA:     {},
+	KeyTypeX25519:  {},
+	KeyTypeHybrid:  {},
+	KeyTypeEd25519: {},
+}
+
+// DefaultRsaKeyBits 未指定长度时生成的 RSA 密钥长度
+const DefaultRsaKeyBits = 3072
+
+// RsaKeyBits 支持的 RSA 密钥长度
+var RsaKeyBits = map[int]struct{}{
+	3072: {},
+	4096: {},
+}
+
+// KeyPaths 返回密钥对的文件路径, name 为空时使用 private.key/public.key
+func KeyPaths(dir, name string) (priPath, pubPath string) {
+	if name == "" {
+		return filepath.Join(dir, "private.key"), filepath.Join(dir, "public.key")
+	}
+	return filepath.Join(dir, name+".private.key"), filepath.Join(dir, name+".public.key")
+}
+
+// KeyOptions 密钥对生成参数
+type KeyOptions struct {
+	Type  string // 密钥类型, 为空时为 RSA
+	Bits  int    // RSA 密钥长度, 为 0 时为 DefaultRsaKeyBits
+	Dir   string
+	Name  string
+	Force bool // 密钥文件已存在时覆盖
+
+	// Passphrase 非空时私钥使用口令加密后存储
+	Passphrase []byte
+	KDF        string
+	KDFCost    int
+}
+
+// GenKeyFiles 生成密钥对并写入 opts.Dir 目录, 私钥文件权限为 0600, 返回未加密的密钥.
+// 密钥文件已存在时, 除非 opts.Force 为 true, 否则返回错误且不修改任何文件.
+func GenKeyFiles(opts *KeyOptions) (pubKey, priKey []byte, err error) {
+	priPath, pubPath := KeyPaths(opts.Dir, opts.Name)
+	if !opts.Force {
+		for _, p := range []string{priPath, pubPath} {
+			if _, err := os.Stat(p); err == nil {
+				return nil, nil, fmt.Errorf("key file %s already exists, use --force to overwrite", p)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var pubBuf, priBuf bytes.Buffer
+	switch opts.Type {
+	case "", KeyTypeRSA:
+		bits := opts.Bits
+		if bits == 0 {
+			bits = DefaultRsaKeyBits
+		}
+		if _, ok := RsaKeyBits[bits]; !ok {
+			return nil, nil, fmt.Errorf("invalid rsa key bits:%d", bits)
+		}
+		err = EncryptionFile.GenRsaKey(bits, &pubBuf, &priBuf)
+	case KeyTypeX25519:
+		err = GenX25519Key(&pubBuf, &priBuf)
+	case KeyTypeHybrid:
+		err = GenHybridKey(&pubBuf, &priBuf)
+	case KeyTypeEd25519:
+		err = GenEd25519Key(&pubBuf, &priBuf)
+	default:
+		err = fmt.Errorf("invalid key type:%s", opts.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	priData := priBuf.Bytes()
+	if len(opts.Passphrase) > 0 {
+		if priData, err = EncryptPrivateKey(priData, opts.Passphrase, opts.KDF, opts.KDFCost); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	if err := writeKeyFile(priPath, priData, 0600, opts.Force); err != nil {
+		return nil, nil, err
+	}
+	if err := writeKeyFile(pubPath, pubBuf.Bytes(), 0644, opts.Force); err != nil {
+		return nil, nil, err
+	}
+	return pubBuf.Bytes(), priBuf.Bytes(), nil
+}
+
+func writeKeyFile(path string, data []byte, perm os.FileMode, force bool) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// 覆盖已存在的文件时 OpenFile 不会修改权限
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Fingerprint 返回 PEM 格式公钥的指纹, 格式为 SHA256:base64(sha256(DER)), 混合公钥为 PEM 内容的哈希
+func Fingerprint(pubKey []byte) (string, error) {
+	block, _ := pem.Decode(pubKey)
+	if block == nil {
+		return "", errors.New("invalid public key pem")
+	}
+	if block.Type == hybridPublicKeyType {
+		if _, _, err := parseHybridPublicKey(pubKey); err != nil {
+			return "", err
+		}
+		return fingerprint(block.Bytes), nil
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return "", err
+	}
+	return fingerprint(block.Bytes), nil
+}
+
+// PrivateKeyFingerprint 返回 PEM 格式私钥对应公钥的指纹, 支持 PKCS#1 与 PKCS#8
+func PrivateKeyFingerprint(priKey []byte) (string, error) {
+	block, _ := pem.Decode(priKey)
+	if block == nil {
+		return "", errors.New("invalid private key pem")
+	}
+	if block.Type == hybridPrivateKeyType {
+		pub, err := hybridPublicKeyBytes(priKey)
+		if err != nil {
+			return "", err
+		}
+		return fingerprint(pub), nil
+	}
+	var (
+		key any
+		err error
+	)
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", err
+	}
+	// rsa/ecdsa/ed25519/ecdh 私钥均实现了 Public 方法
+	priv, ok := key.(interface{ Public() crypto.PublicKey })
+	if !ok {
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return "", err
+	}
+	return fingerprint(der), nil
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// ReadPublicKeys 读取公钥文件与公钥列表文件, 公钥列表文件可包含多个 PEM 格式公钥
+func ReadPublicKeys(paths []string, listFile string) ([][]byte, error) {
+	var keys [][]byte
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, data)
+	}
+	if listFile == "" {
+		return keys, nil
+	}
+
+	data, err := os.ReadFile(listFile)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		keys = append(keys, pem.EncodeToMemory(block))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", listFile)
+	}
+	return keys, nil
+}
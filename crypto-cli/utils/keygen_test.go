@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	pubKey, _, err := GenKeyFiles(&KeyOptions{Bits: 3072, Dir: dir, Name: "test"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+
+	priPath, pubPath := KeyPaths(dir, "test")
+	info, err := os.Stat(priPath)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("private key perm = %o, want 600", perm)
+	}
+	if _, err := os.Stat(pubPath); err != nil {
+		t.Errorf("Stat() public key error = %v", err)
+	}
+
+	// 已存在时拒绝覆盖
+	if _, _, err := GenKeyFiles(&KeyOptions{Bits: 3072, Dir: dir, Name: "test"}); err == nil {
+		t.Errorf("GenKeyFiles() overwrite without force want error")
+	}
+	newPubKey, _, err := GenKeyFiles(&KeyOptions{Bits: 3072, Dir: dir, Name: "test", Force: true})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() force error = %v", err)
+	}
+
+	// 不支持 2048 位以下及 2048 位的 RSA 密钥
+	if _, _, err := GenKeyFiles(&KeyOptions{Bits: 2048, Dir: dir, Name: "weak"}); err == nil {
+		t.Errorf("GenKeyFiles() 2048 bits want error")
+	}
+
+	fp1, err := Fingerprint(pubKey)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	fp2, _ := Fingerprint(newPubKey)
+	if !strings.HasPrefix(fp1, "SHA256:") || fp1 == fp2 {
+		t.Errorf("Fingerprint() = %s, %s", fp1, fp2)
+	}
+}
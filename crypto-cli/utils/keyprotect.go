@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"os"
+	"strings"
+)
+
+// KeyPassphraseEnv 未指定私钥口令文件时, 从该环境变量读取私钥口令
+const KeyPassphraseEnv = "CRYPTO_CLI_KEY_PASSPHRASE"
+
+// encryptedKeyType 口令加密的私钥 PEM 类型, 内容为原私钥 PEM 经口令派生密钥 AES-256-GCM 加密后的数据
+const encryptedKeyType = "CRYPTO-CLI ENCRYPTED PRIVATE KEY"
+
+// EncryptPrivateKey 使用口令加密 PEM 格式的私钥, kdf 参数存储于 PEM 头部
+func EncryptPrivateKey(priKey, passphrase []byte, kdfName string, cost int) ([]byte, error) {
+	kdf, err := NewKDF(kdfName, cost)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := DeriveKey(kdfName, kdf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	data, err := WrapKey(kek, priKey)
+	if err != nil {
+		return nil, err
+	}
+	params, err := json.Marshal(kdf)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type: encryptedKeyType,
+		Headers: map[string]string{
+			"KDF":    kdfName,
+			"Params": string(params),
+		},
+		Bytes: data,
+	}), nil
+}
+
+// IsEncryptedPrivateKey 判断私钥是否经口令加密
+func IsEncryptedPrivateKey(priKey []byte) bool {
+	block, _ := pem.Decode(priKey)
+	return block != nil && block.Type == encryptedKeyType
+}
+
+// DecryptPrivateKey 解密 EncryptPrivateKey 加密的私钥, 返回原私钥 PEM
+func DecryptPrivateKey(data, passphrase []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != encryptedKeyType {
+		return nil, errors.New("not an encrypted private key")
+	}
+	kdf := &header.KDF{}
+	if err := json.Unmarshal([]byte(block.Headers["Params"]), kdf); err != nil {
+		return nil, fmt.Errorf("invalid encrypted private key params: %w", err)
+	}
+	kek, err := DeriveKey(block.Headers["KDF"], kdf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return UnwrapKey(kek, block.Bytes)
+}
+
+// ReadPrivateKey 读取私钥文件, 路径可带 @ 前缀.
+// 私钥经口令加密时, 依次从 passphraseFile, 环境变量 CRYPTO_CLI_KEY_PASSPHRASE, 终端交互输入获取口令并解密.
+func ReadPrivateKey(path, passphraseFile string) ([]byte, error) {
+	priKey, err := os.ReadFile(strings.TrimPrefix(path, "@"))
+	if err != nil {
+		return nil, err
+	}
+	if !IsEncryptedPrivateKey(priKey) {
+		return priKey, nil
+	}
+	pass, err := ReadPassphrase(passphraseFile, KeyPassphraseEnv, "请输入私钥口令", false)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptPrivateKey(priKey, pass)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"os"
+	"testing"
+)
+
+func TestEncryptPrivateKey(t *testing.T) {
+	priKey, err := os.ReadFile("../private.key")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	pass := []byte("key passphrase")
+
+	protected, err := EncryptPrivateKey(priKey, pass, header.StanzaScrypt, scryptMinLogN)
+	if err != nil {
+		t.Fatalf("EncryptPrivateKey() error = %v", err)
+	}
+	if IsEncryptedPrivateKey(priKey) || !IsEncryptedPrivateKey(protected) {
+		t.Fatalf("IsEncryptedPrivateKey() wrong result")
+	}
+	if bytes.Contains(protected, priKey[40:80]) {
+		t.Errorf("EncryptPrivateKey() output contains plaintext key")
+	}
+
+	got, err := DecryptPrivateKey(protected, pass)
+	if err != nil {
+		t.Fatalf("DecryptPrivateKey() error = %v", err)
+	}
+	if !bytes.Equal(got, priKey) {
+		t.Errorf("DecryptPrivateKey() not equal original key")
+	}
+	if _, err := DecryptPrivateKey(protected, []byte("wrong")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("DecryptPrivateKey() wrong passphrase error = %v, want %v", err, ErrWrongPassphrase)
+	}
+
+	// ReadPrivateKey 通过环境变量读取口令
+	path := t.TempDir() + "/protected.key"
+	if err := os.WriteFile(path, protected, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(KeyPassphraseEnv, string(pass))
+	if got, err := ReadPrivateKey("@"+path, ""); err != nil || !bytes.Equal(got, priKey) {
+		t.Errorf("ReadPrivateKey() error = %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxMetadataSize 元数据块的最大长度
+const maxMetadataSize = 1 << 20
+
+// capabilityXattr 保存文件能力的扩展属性, 解密时不恢复
+const capabilityXattr = "security.capability"
+
+// ErrNoMetadata 数据以不完整的元数据块结束
+var ErrNoMetadata = errors.New("metadata: truncated metadata block")
+
+// Metadata 原始文件的元数据, 加密时作为明文的一部分存储于加密数据开头, 解密时可恢复
+type Metadata struct {
+	// Name 原始文件名, 不含目录
+	Name string `json:"name"`
+	// Mode 权限位, 含 setuid/setgid/sticky; 恢复时不恢复 setuid/setgid
+	Mode       os.FileMode `json:"mode"`
+	ModTime    time.Time   `json:"mtime"`
+	AccessTime time.Time   `json:"atime,omitempty"`
+	// Xattrs 扩展属性, 仅 Linux/macOS 支持
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+}
+
+// ReadMetadata 读取文件 f 的元数据
+func ReadMetadata(f string) (*Metadata, error) {
+	info, err := os.Stat(f)
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{
+		Name:    filepath.Base(f),
+		Mode:    info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		ModTime: info.ModTime(),
+	}
+	if m.AccessTime, err = accessTime(f); err != nil {
+		return nil, err
+	}
+	if m.Xattrs, err = readXattrs(f); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Apply 将元数据恢复到文件 f: 扩展属性, 权限与访问/修改时间, 不修改文件名.
+// 元数据来自加密数据, 任何持有接收者公钥的人都可以构造, 因此不恢复 setuid/setgid 位与文件能力(security.capability),
+// 避免以 root 解密时得到以 root 身份运行的文件.
+// 尽量恢复所有项, 返回遇到的第一个错误(如无权限设置 security.* 扩展属性)
+func (m *Metadata) Apply(f string) error {
+	var first error
+	keep := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+	for name, value := range m.Xattrs {
+		if name == capabilityXattr {
+			continue
+		}
+		if err := setXattr(f, name, value); err != nil {
+			keep(fmt.Errorf("set xattr %s: %w", name, err))
+		}
+	}
+	if err := os.Chmod(f, m.Mode&^(os.ModeSetuid|os.ModeSetgid)); err != nil {
+		keep(err)
+	}
+	// 时间最后恢复, 避免被其他修改覆盖
+	if err := os.Chtimes(f, m.AccessTime, m.ModTime); err != nil {
+		keep(err)
+	}
+	return first
+}
+
+// WriteMetadata 将元数据块写入 w: 长度(4, 大端) | 元数据(JSON)
+func WriteMetadata(w io.Writer, m *Metadata) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxMetadataSize {
+		return fmt.Errorf("metadata: size %d exceeds %d", len(body), maxMetadataSize)
+	}
+	buf := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(body)), uint32(len(body)))
+	_, err = w.Write(append(buf, body...))
+	return err
+}
+
+// MetadataSplitter 从写入数据的开头解析 WriteMetadata 写入的元数据块, 之后的数据写入 W
+type MetadataSplitter struct {
+	W io.Writer
+	// Metadata 解析出的元数据, 元数据块完整写入前为 nil
+	Metadata *Metadata
+	buf      []byte
+}
+
+func (s *MetadataSplitter) Write(p []byte) (int, error) {
+	if s.Metadata != nil {
+		return s.W.Write(p)
+	}
+	s.buf = append(s.buf, p...)
+	if len(s.buf) < 4 {
+		return len(p), nil
+	}
+	n := binary.BigEndian.Uint32(s.buf)
+	if n > maxMetadataSize {
+		return 0, fmt.Errorf("metadata: size %d exceeds %d", n, maxMetadataSize)
+	}
+	if len(s.buf) < 4+int(n) {
+		return len(p), nil
+	}
+	m := &Metadata{}
+	if err := json.Unmarshal(s.buf[4:4+n], m); err != nil {
+		return 0, fmt.Errorf("metadata: %w", err)
+	}
+	s.Metadata = m
+	rest := s.buf[4+n:]
+	s.buf = nil
+	if _, err := s.W.Write(rest); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 检查元数据块是否完整, 不关闭 W
+func (s *MetadataSplitter) Close() error {
+	if s.Metadata == nil {
+		return ErrNoMetadata
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// accessTime 不支持的平台不记录访问时间
+func accessTime(f string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+// readXattrs 不支持扩展属性的平台返回 nil
+func readXattrs(f string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func setXattr(f, name string, value []byte) error {
+	return errors.New("xattr is not supported on this platform")
+}
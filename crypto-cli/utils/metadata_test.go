@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	if err := os.WriteFile(src, []byte("data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	atime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	if err := os.Chtimes(src, atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	_ = setXattr(src, "user.test", []byte("value"))
+
+	m, err := ReadMetadata(src)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if m.Name != "src.txt" || m.Mode != 0640 || !m.ModTime.Equal(mtime) {
+		t.Errorf("ReadMetadata() = %+v", m)
+	}
+
+	// 元数据块与数据一同写入, 逐字节写入 MetadataSplitter 后分离
+	var buf bytes.Buffer
+	if err := WriteMetadata(&buf, m); err != nil {
+		t.Fatalf("WriteMetadata() error = %v", err)
+	}
+	buf.WriteString("payload")
+	var out bytes.Buffer
+	s := &MetadataSplitter{W: &out}
+	for _, b := range buf.Bytes() {
+		if _, err := s.Write([]byte{b}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if out.String() != "payload" {
+		t.Errorf("MetadataSplitter data = %q, want payload", out.String())
+	}
+
+	dst := filepath.Join(dir, "dst.txt")
+	if err := os.WriteFile(dst, out.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Metadata.Apply(dst); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	got, err := ReadMetadata(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Name = m.Name
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Apply() metadata = %+v, want %+v", got, m)
+	}
+
+	s = &MetadataSplitter{W: &out}
+	_, _ = s.Write([]byte{0, 0})
+	if err := s.Close(); !errors.Is(err, ErrNoMetadata) {
+		t.Errorf("Close() error = %v, want %v", err, ErrNoMetadata)
+	}
+}
+
+func TestMetadataApplyPrivilegeBits(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "bin")
+	if err := os.WriteFile(f, []byte("#!/bin/sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// 加密数据中的元数据可由任何人构造, setuid/setgid 与文件能力不能被恢复
+	m := &Metadata{
+		Mode:    0755 | os.ModeSetuid | os.ModeSetgid,
+		ModTime: time.Now(),
+		Xattrs:  map[string][]byte{capabilityXattr: {0x1}},
+	}
+	_ = m.Apply(f)
+	info, err := os.Stat(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode(); mode&(os.ModeSetuid|os.ModeSetgid) != 0 || mode.Perm() != 0755 {
+		t.Errorf("Apply() mode = %v, want %v", mode, os.FileMode(0755))
+	}
+	if xattrs, _ := readXattrs(f); xattrs[capabilityXattr] != nil {
+		t.Errorf("Apply() restored %s", capabilityXattr)
+	}
+}
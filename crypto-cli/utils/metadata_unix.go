@@ -0,0 +1,55 @@
+//go:build linux || darwin
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func accessTime(f string) (time.Time, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(f, &st); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(st.Atim.Unix()), nil
+}
+
+// readXattrs 读取文件的扩展属性, 文件系统不支持时返回 nil
+func readXattrs(f string) (map[string][]byte, error) {
+	size, err := unix.Listxattr(f, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Listxattr(f, buf); err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := unix.Getxattr(f, string(name), nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, n)
+		if n, err = unix.Getxattr(f, string(name), value); err != nil {
+			return nil, err
+		}
+		xattrs[string(name)] = value[:n]
+	}
+	return xattrs, nil
+}
+
+func setXattr(f, name string, value []byte) error {
+	return unix.Setxattr(f, name, value, 0)
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/jan-bar/EncryptionFile"
+	"go-crypto/crypto-cli/header"
+)
+
+// ErrNoIdentity 文件头中没有与所提供密钥/口令匹配的接收者
+var ErrNoIdentity = errors.New("no matching recipient found in header")
+
+// Recipient 文件密钥的接收者, 加密时将文件密钥包装为文件头中的一个 Stanza
+type Recipient interface {
+	Wrap(fileKey []byte) (*header.Stanza, error)
+}
+
+// Identity 解密时使用, 从文件头的 Stanza 中找到匹配项并解出文件密钥
+type Identity interface {
+	Unwrap(stanzas []header.Stanza) ([]byte, error)
+}
+
+// KeyType 识别 PEM 格式公钥或私钥的类型, 返回 KeyTypeRSA, KeyTypeX25519, KeyTypeHybrid 或 KeyTypeEd25519
+func KeyType(key []byte) (string, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return "", errors.New("invalid key pem")
+	}
+	var (
+		k   any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return KeyTypeRSA, nil
+	case hybridPublicKeyType, hybridPrivateKeyType:
+		return KeyTypeHybrid, nil
+	case "PUBLIC KEY":
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		k, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return "", fmt.Errorf("unsupported pem type:%s", block.Type)
+	}
+	if err != nil {
+		return "", err
+	}
+	switch k := k.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return KeyTypeRSA, nil
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return KeyTypeEd25519, nil
+	case *ecdh.PublicKey:
+		if k.Curve() == ecdh.X25519() {
+			return KeyTypeX25519, nil
+		}
+	case *ecdh.PrivateKey:
+		if k.Curve() == ecdh.X25519() {
+			return KeyTypeX25519, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported key type %T", k)
+}
+
+// NewRecipient 按公钥类型返回对应的 Recipient
+func NewRecipient(pubKey []byte) (Recipient, error) {
+	keyType, err := KeyType(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	switch keyType {
+	case KeyTypeX25519:
+		return &X25519Recipient{PubKey: pubKey}, nil
+	case KeyTypeHybrid:
+		return &HybridRecipient{PubKey: pubKey}, nil
+	case KeyTypeEd25519:
+		return nil, errors.New("ed25519 key can only be used for signing")
+	}
+	return &RSARecipient{PubKey: pubKey}, nil
+}
+
+// NewIdentity 按私钥类型返回对应的 Identity, priKey 须为未加密的私钥
+func NewIdentity(priKey []byte) (Identity, error) {
+	keyType, err := KeyType(priKey)
+	if err != nil {
+		return nil, err
+	}
+	switch keyType {
+	case KeyTypeX25519:
+		return &X25519Identity{PriKey: priKey}, nil
+	case KeyTypeHybrid:
+		return &HybridIdentity{PriKey: priKey}, nil
+	case KeyTypeEd25519:
+		return nil, errors.New("ed25519 key can only be used for signing")
+	}
+	return &RSAIdentity{PriKey: priKey}, nil
+}
+
+// RSARecipient 使用 RSA 公钥包装文件密钥
+type RSARecipient struct {
+	PubKey []byte
+}
+
+func (r *RSARecipient) Wrap(fileKey []byte) (*header.Stanza, error) {
+	fp, err := Fingerprint(r.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := EncryptionFile.RsaEncrypt(r.PubKey, fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrap file key: %w", err)
+	}
+	return &header.Stanza{Type: header.StanzaRSA, Fingerprint: fp, Key: encKey}, nil
+}
+
+// RSAIdentity 使用 RSA 私钥解出文件密钥
+type RSAIdentity struct {
+	PriKey []byte
+}
+
+// Unwrap 按公钥指纹查找匹配的接收者, 未记录指纹的接收者逐个尝试解密
+func (i *RSAIdentity) Unwrap(stanzas []header.Stanza) ([]byte, error) {
+	fp, err := PrivateKeyFingerprint(i.PriKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stanzas {
+		if s.Type != header.StanzaRSA || (s.Fingerprint != "" && s.Fingerprint != fp) {
+			continue
+		}
+		key, err := EncryptionFile.RsaDecrypt(i.PriKey, s.Key)
+		if err != nil {
+			if s.Fingerprint == "" {
+				continue
+			}
+			return nil, fmt.Errorf("unwrap file key: %w", err)
+		}
+		return key, nil
+	}
+	return nil, ErrNoIdentity
+}
+
+// PassphraseRecipient 使用口令派生的密钥包装文件密钥
+type PassphraseRecipient struct {
+	KDF        string // header.StanzaScrypt 或 header.StanzaArgon2id
+	Cost       int
+	Passphrase []byte
+}
+
+func (r *PassphraseRecipient) Wrap(fileKey []byte) (*header.Stanza, error) {
+	kdf, err := NewKDF(r.KDF, r.Cost)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := DeriveKey(r.KDF, kdf, r.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := WrapKey(kek, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &header.Stanza{Type: r.KDF, KDF: kdf, Key: encKey}, nil
+}
+
+// PassphraseIdentity 使用口令解出文件密钥
+type PassphraseIdentity struct {
+	Passphrase []byte
+}
+
+func (i *PassphraseIdentity) Unwrap(stanzas []header.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != header.StanzaScrypt && s.Type != header.StanzaArgon2id {
+			continue
+		}
+		kek, err := DeriveKey(s.Type, s.KDF, i.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return UnwrapKey(kek, s.Key)
+	}
+	return nil, ErrNoIdentity
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"os"
+	"testing"
+)
+
+func TestRSARecipients(t *testing.T) {
+	dir := t.TempDir()
+	pubA, priA, err := GenKeyFiles(&KeyOptions{Bits: 3072, Dir: dir, Name: "a"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	_, pubPathA := KeyPaths(dir, "a")
+	pubB, err := os.ReadFile("../public.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	priB, err := os.ReadFile("../private.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 公钥列表文件包含多个公钥
+	listFile := dir + "/team.keys"
+	if err := os.WriteFile(listFile, append(append([]byte{}, pubA...), pubB...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pubKeys, err := ReadPublicKeys([]string{pubPathA}, listFile)
+	if err != nil {
+		t.Fatalf("ReadPublicKeys() error = %v", err)
+	}
+	if len(pubKeys) != 3 {
+		t.Fatalf("ReadPublicKeys() got %d keys, want 3", len(pubKeys))
+	}
+
+	fileKey := bytes.Repeat([]byte{0x7}, 32)
+	var stanzas []header.Stanza
+	for _, pubKey := range [][]byte{pubA, pubB} {
+		s, err := (&RSARecipient{PubKey: pubKey}).Wrap(fileKey)
+		if err != nil {
+			t.Fatalf("Wrap() error = %v", err)
+		}
+		stanzas = append(stanzas, *s)
+	}
+
+	for name, priKey := range map[string][]byte{"a": priA, "b": priB} {
+		got, err := (&RSAIdentity{PriKey: priKey}).Unwrap(stanzas)
+		if err != nil {
+			t.Fatalf("Unwrap() %s error = %v", name, err)
+		}
+		if !bytes.Equal(got, fileKey) {
+			t.Errorf("Unwrap() %s = %x, want %x", name, got, fileKey)
+		}
+	}
+
+	if _, err := (&RSAIdentity{PriKey: priB}).Unwrap(stanzas[:1]); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("Unwrap() without matching recipient error = %v, want %v", err, ErrNoIdentity)
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// shredBufSize 每次写入的随机数据长度
+const shredBufSize = 64 << 10
+
+// Shred 用随机数据覆盖文件 f 的全部内容 passes 遍, 每遍后同步到磁盘, 最后截断为 0 并同步, 不关闭也不删除文件.
+// 文件有多个硬链接时拒绝覆盖, 避免破坏其他链接的数据.
+// 覆盖只作用于文件当前的数据块, 能否真正擦除取决于文件系统与存储设备, 见 ShredBestEffort.
+func Shred(f *os.File, passes int) error {
+	if passes < 1 {
+		return fmt.Errorf("invalid shred passes:%d", passes)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("shred: not a regular file")
+	}
+	if n := linkCount(info); n > 1 {
+		return fmt.Errorf("shred: file has %d hard links", n)
+	}
+
+	size := info.Size()
+	buf := make([]byte, shredBufSize)
+	for i := 0; i < passes; i++ {
+		for off := int64(0); off < size; {
+			n := int64(len(buf))
+			if size-off < n {
+				n = size - off
+			}
+			if _, err := rand.Read(buf[:n]); err != nil {
+				return err
+			}
+			if _, err := f.WriteAt(buf[:n], off); err != nil {
+				return err
+			}
+			off += n
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
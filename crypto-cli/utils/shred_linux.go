@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// 覆盖写不会写到原数据块上的文件系统, 来自 statfs(2)
+var bestEffortFS = map[int64]string{
+	unix.BTRFS_SUPER_MAGIC:     "btrfs (copy-on-write)",
+	0x2fc12fc1:                 "zfs (copy-on-write)",
+	unix.F2FS_SUPER_MAGIC:      "f2fs (log-structured)",
+	unix.NILFS_SUPER_MAGIC:     "nilfs (log-structured)",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlayfs (lower layer is not overwritten)",
+	unix.NFS_SUPER_MAGIC:       "nfs (network filesystem)",
+	unix.CIFS_SUPER_MAGIC:      "cifs (network filesystem)",
+	unix.SMB2_SUPER_MAGIC:      "smb2 (network filesystem)",
+	unix.FUSE_SUPER_MAGIC:      "fuse (unknown backing storage)",
+}
+
+// ShredBestEffort 返回覆盖文件 f 只能尽力而为的原因, 为空表示文件系统原地覆盖数据.
+// 即使如此, SSD 的磨损均衡, 文件系统快照与备份仍可能保留原数据.
+func ShredBestEffort(f string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(f, &st); err != nil {
+		return fmt.Sprintf("unknown filesystem: %s", err)
+	}
+	return bestEffortFS[int64(st.Type)]
+}
+
+func linkCount(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
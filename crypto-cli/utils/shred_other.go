@@ -0,0 +1,14 @@
+//go:build !linux
+
+package utils
+
+import "os"
+
+// ShredBestEffort 返回覆盖文件 f 只能尽力而为的原因, 非 Linux 平台无法识别文件系统
+func ShredBestEffort(f string) string {
+	return "filesystem type is unknown on this platform"
+}
+
+func linkCount(info os.FileInfo) uint64 {
+	return 1
+}
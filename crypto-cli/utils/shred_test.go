@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShred(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(p, make([]byte, shredBufSize*2+10), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := Shred(f, 0); err == nil {
+		t.Errorf("Shred() passes 0 error = nil")
+	}
+	if err := Shred(f, 2); err != nil {
+		t.Fatalf("Shred() error = %v", err)
+	}
+	if info, _ := os.Stat(p); info.Size() != 0 {
+		t.Errorf("Shred() size = %d, want 0", info.Size())
+	}
+
+	// 有多个硬链接时拒绝覆盖
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(p, link); err != nil {
+		t.Skip("hard link is not supported:", err)
+	}
+	if linkCount(mustStat(t, p)) > 1 {
+		if err := Shred(f, 1); err == nil {
+			t.Errorf("Shred() hard link error = nil")
+		}
+	}
+}
+
+func mustStat(t *testing.T, p string) os.FileInfo {
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
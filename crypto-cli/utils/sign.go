@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"hash"
+	"io"
+)
+
+// signatureType 分离签名文件的 PEM 类型, 签名算法与签名者指纹存储于 PEM 头部
+const signatureType = "CRYPTO-CLI SIGNATURE"
+
+var (
+	// ErrBadSignature 签名验证失败
+	ErrBadSignature = errors.New("signature verification failed")
+	// ErrNoSignature 文件未签名
+	ErrNoSignature = errors.New("file is not signed")
+)
+
+// GenEd25519Key 生成 Ed25519 签名密钥对, 私钥为 PKCS#8 PEM, 公钥为 PKIX PEM
+func GenEd25519Key(pub, pri io.Writer) error {
+	pubKey, priKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priKey)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(pri, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return err
+	}
+	der, err = x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return err
+	}
+	return pem.Encode(pub, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// Signer 使用 Ed25519 或 RSA 私钥对明文摘要签名, Ed25519 使用 Ed25519ph, RSA 使用 RSA-PSS
+type Signer struct {
+	key    crypto.Signer
+	alg    string
+	pubKey []byte
+	fp     string
+}
+
+// NewSigner 根据 PEM 格式私钥创建 Signer, priKey 须为未加密的 Ed25519 或 RSA 私钥
+func NewSigner(priKey []byte) (*Signer, error) {
+	block, _ := pem.Decode(priKey)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+	var (
+		key any
+		err error
+	)
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Signer{}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		s.key, s.alg = k, header.SignatureEd25519
+	case *rsa.PrivateKey:
+		s.key, s.alg = k, header.SignatureRSAPSS
+	default:
+		return nil, fmt.Errorf("key type %T can not be used for signing, use ed25519 or rsa", key)
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	s.pubKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	s.fp = fingerprint(der)
+	return s, nil
+}
+
+// Hash 返回计算待签名摘要所用的哈希
+func (s *Signer) Hash() hash.Hash {
+	h, _ := SignatureHash(s.alg)
+	return h
+}
+
+// Sign 对摘要签名, 返回的签名不含公钥
+func (s *Signer) Sign(digest []byte) (*header.Signature, error) {
+	var (
+		sig []byte
+		err error
+	)
+	switch s.alg {
+	case header.SignatureEd25519:
+		sig, err = s.key.Sign(rand.Reader, digest, &ed25519.Options{Hash: crypto.SHA512})
+	default:
+		sig, err = s.key.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &header.Signature{Algorithm: s.alg, Fingerprint: s.fp, Signature: sig}, nil
+}
+
+// PublicKey 返回签名者的 PEM 格式公钥
+func (s *Signer) PublicKey() []byte {
+	return s.pubKey
+}
+
+// SignatureHash 返回签名算法所用的哈希
+func SignatureHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case header.SignatureEd25519:
+		return sha512.New(), nil
+	case header.SignatureRSAPSS:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported signature algorithm:%s", alg)
+}
+
+// VerifySignature 使用 PEM 格式公钥验证摘要的签名, 签名无效时返回 ErrBadSignature
+func VerifySignature(pubKey []byte, sig *header.Signature, digest []byte) error {
+	block, _ := pem.Decode(pubKey)
+	if block == nil {
+		return errors.New("invalid public key pem")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if fp := fingerprint(block.Bytes); sig.Fingerprint != fp {
+		return fmt.Errorf("%w: signed by %s, not %s", ErrBadSignature, sig.Fingerprint, fp)
+	}
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		if sig.Algorithm != header.SignatureEd25519 {
+			break
+		}
+		if err := ed25519.VerifyWithOptions(k, digest, sig.Signature, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+			return ErrBadSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		if sig.Algorithm != header.SignatureRSAPSS {
+			break
+		}
+		if err := rsa.VerifyPSS(k, crypto.SHA256, digest, sig.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+			return ErrBadSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: algorithm %s does not match key type %T", ErrBadSignature, sig.Algorithm, pub)
+}
+
+// Digest 计算 r 的内容在签名算法 alg 下的摘要
+func Digest(alg string, r io.Reader) ([]byte, error) {
+	h, err := SignatureHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// EncodeSignature 将签名编码为分离签名文件内容
+func EncodeSignature(sig *header.Signature) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type: signatureType,
+		Headers: map[string]string{
+			"Algorithm":   sig.Algorithm,
+			"Fingerprint": sig.Fingerprint,
+		},
+		Bytes: sig.Signature,
+	})
+}
+
+// DecodeSignature 解析分离签名文件内容
+func DecodeSignature(data []byte) (*header.Signature, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != signatureType {
+		return nil, errors.New("invalid signature file")
+	}
+	return &header.Signature{
+		Algorithm:   block.Headers["Algorithm"],
+		Fingerprint: block.Headers["Fingerprint"],
+		Signature:   block.Bytes,
+	}, nil
+}
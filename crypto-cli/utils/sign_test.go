@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	edPub, edPri, err := GenKeyFiles(&KeyOptions{Type: KeyTypeEd25519, Dir: dir, Name: "ed"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	rsaPub, err := os.ReadFile("../public.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPri, err := os.ReadFile("../private.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	tests := []struct {
+		name   string
+		pubKey []byte
+		priKey []byte
+	}{
+		{name: "ed25519", pubKey: edPub, priKey: edPri},
+		{name: "rsa-pss", pubKey: rsaPub, priKey: rsaPri},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner(tt.priKey)
+			if err != nil {
+				t.Fatalf("NewSigner() error = %v", err)
+			}
+			h := signer.Hash()
+			h.Write(data)
+			sig, err := signer.Sign(h.Sum(nil))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			// 经分离签名文件编码后验证
+			sig, err = DecodeSignature(EncodeSignature(sig))
+			if err != nil {
+				t.Fatalf("DecodeSignature() error = %v", err)
+			}
+			digest, err := Digest(sig.Algorithm, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Digest() error = %v", err)
+			}
+			if err := VerifySignature(tt.pubKey, sig, digest); err != nil {
+				t.Errorf("VerifySignature() error = %v", err)
+			}
+			if fp, _ := Fingerprint(tt.pubKey); sig.Fingerprint != fp {
+				t.Errorf("Fingerprint = %s, want %s", sig.Fingerprint, fp)
+			}
+
+			tampered, _ := Digest(sig.Algorithm, bytes.NewReader(append([]byte("x"), data...)))
+			if err := VerifySignature(tt.pubKey, sig, tampered); !errors.Is(err, ErrBadSignature) {
+				t.Errorf("VerifySignature() tampered error = %v, want %v", err, ErrBadSignature)
+			}
+		})
+	}
+
+	// 公钥与签名者不一致
+	signer, _ := NewSigner(edPri)
+	sig, _ := signer.Sign(make([]byte, 64))
+	if err := VerifySignature(rsaPub, sig, make([]byte, 64)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifySignature() wrong key error = %v, want %v", err, ErrBadSignature)
+	}
+
+	// 加密用密钥不能签名, 签名密钥不能加密
+	_, xPri, _ := GenKeyFiles(&KeyOptions{Type: KeyTypeX25519, Dir: dir, Name: "x"})
+	if _, err := NewSigner(xPri); err == nil {
+		t.Errorf("NewSigner() x25519 key want error")
+	}
+	if _, err := NewRecipient(edPub); err == nil {
+		t.Errorf("NewRecipient() ed25519 key want error")
+	}
+}
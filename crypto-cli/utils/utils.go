@@ -1,9 +1,9 @@
 package utils
 
 import (
-	"bytes"
 	"crypto/cipher"
 	"encoding/hex"
+	"fmt"
 	"github.com/jan-bar/EncryptionFile"
 	"go-crypto/aes"
 	"go-crypto/crypto-cli/config"
@@ -62,44 +62,11 @@ func repeat(src []byte, size int) []byte {
 	return dst
 }
 
-func GenRsaKey() (pubKey, priKey []byte, err error) {
-	bufLen := 32 * 1024
-	bufLen2 := 2 * bufLen
-	var (
-		tmpBuf0 = bytes.NewBuffer(make([]byte, 0, bufLen2))
-		tmpBuf1 = bytes.NewBuffer(make([]byte, 0, bufLen2))
-	)
-
-	err = EncryptionFile.GenRsaKey(0, tmpBuf0, tmpBuf1)
+// GenKey 在当前目录生成 keyType 类型的 private.key/public.key 密钥对, 文件已存在时返回错误
+func GenKey(keyType string) (pubKey, priKey []byte, err error) {
+	pubKey, priKey, err = GenKeyFiles(&KeyOptions{Type: keyType, Dir: "."})
 	if err != nil {
 		log.Println(err)
-		return nil, nil, err
-	}
-
-	priKey = make([]byte, tmpBuf1.Len())
-	pubKey = make([]byte, tmpBuf0.Len())
-	copy(priKey, tmpBuf1.Bytes()) // save the private key
-	copy(pubKey, tmpBuf0.Bytes()) // save the public key
-	privateFile, err := os.OpenFile("private.key", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		log.Println(err)
-		return nil, nil, err
-	}
-	defer privateFile.Close()
-	publicFile, err := os.OpenFile("public.key", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		log.Println(err)
-		return nil, nil, err
-	}
-	defer publicFile.Close()
-
-	if _, err := privateFile.Write(priKey); err != nil {
-		log.Println(err)
-		return nil, nil, err
-	}
-	if _, err := publicFile.Write(pubKey); err != nil {
-		log.Println(err)
-		return nil, nil, err
 	}
 	return
 }
@@ -118,6 +85,8 @@ func InitEncCipher(c *config.Config) EncryptionFile.EncCipher {
 		return EncryptionFile.GenEncCipher(cipher.NewCTR)
 	case aes.ModeOFB:
 		return EncryptionFile.GenEncCipher(cipher.NewOFB)
+	case aes.ModeGCM:
+		return EncryptionFile.GenEncCipher(cipher.NewGCM)
 	default:
 		log.Fatalf("[FATA] invalid security mode:%s", c.Security)
 		return nil
@@ -138,8 +107,31 @@ func InitDecCipher(c *config.Config) EncryptionFile.DecCipher {
 		return EncryptionFile.GenDecCipher(cipher.NewCTR)
 	case aes.ModeOFB:
 		return EncryptionFile.GenDecCipher(cipher.NewOFB)
+	case aes.ModeGCM:
+		return EncryptionFile.GenDecCipher(cipher.NewGCM)
 	default:
 		log.Fatalf("[FATA] invalid security mode:%s", c.Security)
 		return nil
 	}
 }
+
+// ParseRange 解析 start:end 形式的明文字节范围, 包含 start 不包含 end.
+// start 省略表示从头开始, end 省略表示直到结尾, 此时返回的 end 为 -1.
+func ParseRange(s string) (start, end int64, err error) {
+	startStr, endStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range:%s, want start:end", s)
+	}
+	end = -1
+	if startStr != "" {
+		if start, err = strconv.ParseInt(startStr, 10, 64); err != nil || start < 0 {
+			return 0, 0, fmt.Errorf("invalid range start:%s", startStr)
+		}
+	}
+	if endStr != "" {
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid range end:%s", endStr)
+		}
+	}
+	return start, end, nil
+}
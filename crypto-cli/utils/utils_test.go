@@ -23,31 +23,37 @@ func TestInitAesEncryptor(t *testing.T) {
 		{
 			name: "key-from-str",
 			args: args{&config.Config{
-				Key:      "adddd",
-				Security: "aes-128-cbc",
-				File:     "",
-				Out:      "",
-				Nonce:    0,
+				PrivateKey: "adddd",
+				Security:   "aes-128-cbc",
+				File:       "",
+				Out:        "",
 			},
 			},
 		}, {
 			name: "key-from-file",
 			args: args{&config.Config{
-				Key:      "@private.key",
-				Security: "aes-256-cbc",
-				File:     "",
-				Out:      "",
-				Nonce:    0,
+				PrivateKey: "@../private.key",
+				Security:   "aes-256-cbc",
+				File:       "",
+				Out:        "",
 			},
 			},
 		}, {
 			name: "key-from-file",
 			args: args{&config.Config{
-				Key:      "aaaaaaddddd",
-				Security: "aes-256-cbc",
-				File:     "",
-				Out:      "",
-				Nonce:    0,
+				PrivateKey: "aaaaaaddddd",
+				Security:   "aes-256-cbc",
+				File:       "",
+				Out:        "",
+			},
+			},
+		}, {
+			name: "gcm",
+			args: args{&config.Config{
+				PrivateKey: "aaaaaaddddd",
+				Security:   "aes-256-gcm",
+				File:       "",
+				Out:        "",
 			},
 			},
 		},
@@ -123,3 +129,33 @@ func Test_repeat(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantStart int64
+		wantEnd   int64
+		wantErr   bool
+	}{
+		{name: "full", s: "10:20", wantStart: 10, wantEnd: 20},
+		{name: "no-start", s: ":20", wantStart: 0, wantEnd: 20},
+		{name: "no-end", s: "10:", wantStart: 10, wantEnd: -1},
+		{name: "empty", s: "10:10", wantStart: 10, wantEnd: 10},
+		{name: "no-colon", s: "10", wantErr: true},
+		{name: "negative", s: "-1:10", wantErr: true},
+		{name: "end-before-start", s: "20:10", wantErr: true},
+		{name: "not-number", s: "a:b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ParseRange(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (start != tt.wantStart || end != tt.wantEnd) {
+				t.Errorf("ParseRange() = %d, %d, want %d, %d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
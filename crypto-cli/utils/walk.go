@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// WalkFiles 递归遍历目录 root, 返回其下所有普通文件相对 root 的路径(以 / 分隔), 按字典序排列.
+// include 不为空时只返回匹配其中任一通配符的文件; 匹配 exclude 的文件与目录被跳过.
+// 通配符语法同 path.Match, 与相对路径或文件名匹配, 如 *.log 或 logs/*.log.
+// 符号链接等非普通文件被忽略.
+func WalkFiles(root string, include, exclude []string) ([]string, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if MatchPath(exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(include) > 0 && !MatchPath(include, rel) {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// MatchPath 判断以 / 分隔的相对路径 rel 或其文件名是否匹配 patterns 中任一通配符
+func MatchPath(patterns []string, rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
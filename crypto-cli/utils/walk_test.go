@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"a.txt", "b.log", "sub/c.txt", "sub/d.log", "tmp/e.txt", "tmp/deep/f.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{name: "all", want: []string{"a.txt", "b.log", "sub/c.txt", "sub/d.log", "tmp/deep/f.txt", "tmp/e.txt"}},
+		{name: "include-base", include: []string{"*.log"}, want: []string{"b.log", "sub/d.log"}},
+		{name: "include-path", include: []string{"sub/*"}, want: []string{"sub/c.txt", "sub/d.log"}},
+		{name: "exclude-dir", exclude: []string{"tmp"}, want: []string{"a.txt", "b.log", "sub/c.txt", "sub/d.log"}},
+		{name: "include-exclude", include: []string{"*.txt"}, exclude: []string{"sub/*", "tmp"}, want: []string{"a.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WalkFiles(root, tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("WalkFiles() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WalkFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := WalkFiles(root, []string{"[a-"}, nil); err == nil {
+		t.Errorf("WalkFiles() invalid pattern error = nil")
+	}
+}
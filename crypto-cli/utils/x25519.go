@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-crypto/crypto-cli/header"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// x25519Info HKDF 派生包装密钥时使用的上下文信息
+const x25519Info = "go-crypto/x25519"
+
+// GenX25519Key 生成 X25519 密钥对, 私钥为 PKCS#8 PEM, 公钥为 PKIX PEM
+func GenX25519Key(pub, pri io.Writer) error {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(pri, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return err
+	}
+	der, err = x509.MarshalPKIXPublicKey(key.PublicKey())
+	if err != nil {
+		return err
+	}
+	return pem.Encode(pub, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// X25519Recipient 使用 X25519 ECDH 协商的密钥包装文件密钥:
+// 生成临时密钥对与接收者公钥协商共享密钥, 经 HKDF-SHA256 派生包装密钥, 再用 AES-256-GCM 加密文件密钥.
+type X25519Recipient struct {
+	PubKey []byte
+}
+
+func (r *X25519Recipient) Wrap(fileKey []byte) (*header.Stanza, error) {
+	pub, err := parseX25519PublicKey(r.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := Fingerprint(r.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	epk := ephemeral.PublicKey().Bytes()
+	kek, err := x25519KEK(shared, epk, pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := WrapKey(kek, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return &header.Stanza{Type: header.StanzaX25519, Fingerprint: fp, EphemeralKey: epk, Key: encKey}, nil
+}
+
+// X25519Identity 使用 X25519 私钥解出文件密钥
+type X25519Identity struct {
+	PriKey []byte
+}
+
+func (i *X25519Identity) Unwrap(stanzas []header.Stanza) ([]byte, error) {
+	key, err := parseX25519PrivateKey(i.PriKey)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := PrivateKeyFingerprint(i.PriKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stanzas {
+		if s.Type != header.StanzaX25519 || s.Fingerprint != fp {
+			continue
+		}
+		epk, err := ecdh.X25519().NewPublicKey(s.EphemeralKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x25519 ephemeral key: %w", err)
+		}
+		shared, err := key.ECDH(epk)
+		if err != nil {
+			return nil, err
+		}
+		kek, err := x25519KEK(shared, s.EphemeralKey, key.PublicKey().Bytes())
+		if err != nil {
+			return nil, err
+		}
+		fileKey, err := UnwrapKey(kek, s.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap file key: %w", err)
+		}
+		return fileKey, nil
+	}
+	return nil, ErrNoIdentity
+}
+
+// x25519KEK 由共享密钥派生包装密钥, 盐为临时公钥与接收者公钥, 将协商双方绑定到派生结果
+func x25519KEK(shared, epk, recipient []byte) ([]byte, error) {
+	salt := make([]byte, 0, len(epk)+len(recipient))
+	salt = append(append(salt, epk...), recipient...)
+	kek := make([]byte, kdfKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), kek); err != nil {
+		return nil, err
+	}
+	return kek, nil
+}
+
+func parseX25519PublicKey(pubKey []byte) (*ecdh.PublicKey, error) {
+	block, _ := pem.Decode(pubKey)
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := pub.(*ecdh.PublicKey); ok && k.Curve() == ecdh.X25519() {
+		return k, nil
+	}
+	return nil, fmt.Errorf("not a x25519 public key: %T", pub)
+}
+
+func parseX25519PrivateKey(priKey []byte) (*ecdh.PrivateKey, error) {
+	block, _ := pem.Decode(priKey)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := key.(*ecdh.PrivateKey); ok && k.Curve() == ecdh.X25519() {
+		return k, nil
+	}
+	return nil, fmt.Errorf("not a x25519 private key: %T", key)
+}
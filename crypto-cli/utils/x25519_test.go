@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"go-crypto/crypto-cli/header"
+	"testing"
+)
+
+func TestX25519Recipients(t *testing.T) {
+	dir := t.TempDir()
+	pubA, priA, err := GenKeyFiles(&KeyOptions{Type: KeyTypeX25519, Dir: dir, Name: "a"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	pubB, priB, err := GenKeyFiles(&KeyOptions{Type: KeyTypeX25519, Dir: dir, Name: "b"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+
+	fileKey := bytes.Repeat([]byte{0x7}, 32)
+	var stanzas []header.Stanza
+	for _, pubKey := range [][]byte{pubA, pubB} {
+		r, err := NewRecipient(pubKey)
+		if err != nil {
+			t.Fatalf("NewRecipient() error = %v", err)
+		}
+		if _, ok := r.(*X25519Recipient); !ok {
+			t.Fatalf("NewRecipient() = %T, want *X25519Recipient", r)
+		}
+		s, err := r.Wrap(fileKey)
+		if err != nil {
+			t.Fatalf("Wrap() error = %v", err)
+		}
+		if s.Type != header.StanzaX25519 || len(s.EphemeralKey) != 32 {
+			t.Errorf("Wrap() stanza type = %s, epk length = %d", s.Type, len(s.EphemeralKey))
+		}
+		stanzas = append(stanzas, *s)
+	}
+
+	for name, priKey := range map[string][]byte{"a": priA, "b": priB} {
+		id, err := NewIdentity(priKey)
+		if err != nil {
+			t.Fatalf("NewIdentity() %s error = %v", name, err)
+		}
+		got, err := id.Unwrap(stanzas)
+		if err != nil {
+			t.Fatalf("Unwrap() %s error = %v", name, err)
+		}
+		if !bytes.Equal(got, fileKey) {
+			t.Errorf("Unwrap() %s = %x, want %x", name, got, fileKey)
+		}
+	}
+
+	if _, err := (&X25519Identity{PriKey: priB}).Unwrap(stanzas[:1]); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("Unwrap() without matching recipient error = %v, want %v", err, ErrNoIdentity)
+	}
+
+	// 篡改临时公钥后无法解出文件密钥
+	stanzas[0].EphemeralKey[0] ^= 0x1
+	if _, err := (&X25519Identity{PriKey: priA}).Unwrap(stanzas[:1]); err == nil {
+		t.Errorf("Unwrap() tampered ephemeral key want error")
+	}
+}
+
+func TestKeyType(t *testing.T) {
+	dir := t.TempDir()
+	xPub, xPri, err := GenKeyFiles(&KeyOptions{Type: KeyTypeX25519, Dir: dir, Name: "x"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+	rPub, rPri, err := GenKeyFiles(&KeyOptions{Bits: 3072, Dir: dir, Name: "r"})
+	if err != nil {
+		t.Fatalf("GenKeyFiles() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		key  []byte
+		want string
+	}{
+		{"x25519-public", xPub, KeyTypeX25519},
+		{"x25519-private", xPri, KeyTypeX25519},
+		{"rsa-public", rPub, KeyTypeRSA},
+		{"rsa-private", rPri, KeyTypeRSA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := KeyType(tt.key)
+			if err != nil {
+				t.Fatalf("KeyType() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("KeyType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := KeyType([]byte("not a key")); err == nil {
+		t.Errorf("KeyType() invalid pem want error")
+	}
+}